@@ -19,8 +19,9 @@ func (a *pathAliases) ForPath(path string) string {
 }
 
 var alias = pathAliases{
-	"/":      "/index.html",
-	"/admin": "/admin.html",
+	"/":        "/index.html",
+	"/admin":   "/admin.html",
+	"/present": "/presenter.html",
 }
 
 // Handler serves static files which have been built into the pijector
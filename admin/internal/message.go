@@ -0,0 +1,78 @@
+package internal
+
+// MessagePage is the html/template from which messages are rendered for
+// screens, styled after DefaultPage. Its data is the message's Body, Theme,
+// Markdown flag and, for a countdown, the Until and Now times in milliseconds
+// since the epoch.
+var MessagePage = `<!DOCTYPE html>
+<html><head><style>
+body,html {
+	background-color: #333;
+	color: #eee;
+	font-family: serif;
+}
+body.light {
+	background-color: #eee;
+	color: #222;
+}
+body.info {
+	background-color: #1c3f66;
+	color: #eef;
+}
+body.warning {
+	background-color: #6b4e00;
+	color: #fff6dd;
+}
+body.alert {
+	background-color: #7a1414;
+	color: #fee;
+}
+a {
+	color: inherit;
+}
+div#main-content {
+	margin: 25% 5% 0 5%;
+	font-size: xx-large;
+	text-align: center;
+}
+div#main-content p.text {
+	white-space: pre-wrap;
+}
+div#main-content ul,
+div#main-content ol {
+	display: inline-block;
+	text-align: left;
+}
+p#countdown {
+	font-size: 2em;
+	font-variant-numeric: tabular-nums;
+}
+p.subtle {
+	font-size: small;
+	color: #666;
+}
+</style><title>Pijector Message</title></head>
+<body class="{{.Theme}}">
+<div id="main-content">
+{{if .Markdown}}{{.Body}}{{else}}<p class="text">{{.Body}}</p>{{end}}
+{{if .Until}}<p id="countdown"></p>{{end}}
+</div>
+{{if .Until}}<script type="text/javascript">
+((until, now) => {
+	// Count down by the server's clock, which may not agree with the screen's.
+	const skew = Date.now() - now;
+	const node = document.getElementById('countdown');
+	const pad = (n) => String(n).padStart(2, '0');
+	const tick = () => {
+		const left = Math.max(0, Math.ceil((until - (Date.now() - skew)) / 1000));
+		const h = Math.floor(left / 3600), m = Math.floor(left / 60) % 60, s = left % 60;
+		node.textContent = (h ? h + ':' + pad(m) : m) + ':' + pad(s);
+		if (left > 0) {
+			setTimeout(tick, 250);
+		}
+	};
+	tick();
+})({{.Until}}, {{.Now}});
+</script>{{end}}
+</body></html>
+`
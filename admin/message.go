@@ -0,0 +1,145 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cfunkhouser/pijector/admin/internal"
+	"github.com/russross/blackfriday/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// MessagePath is where messages are rendered for screens to show.
+const MessagePath = "/message"
+
+var (
+	// ErrNoMessage is returned for messages without any text.
+	ErrNoMessage = errors.New("message has no text")
+	// ErrUnknownTheme is returned for messages in a theme which doesn't exist.
+	ErrUnknownTheme = errors.New("unknown message theme")
+)
+
+// Themes in which messages can be shown. The first is the default, which
+// matches the default page.
+var Themes = []string{"dark", "light", "info", "warning", "alert"}
+
+var messagePage = template.Must(template.New("message").Parse(internal.MessagePage))
+
+// Message shown on a screen without a page of its own.
+type Message struct {
+	Text string
+	// Markdown is set if Text is Markdown rather than plain text.
+	Markdown bool
+	Theme    string
+	// Until is when a countdown shown below the message runs out. There is no
+	// countdown if it is zero.
+	Until time.Time
+}
+
+// Check that the message can be shown, filling in its default theme.
+func (m *Message) Check() error {
+	if strings.TrimSpace(m.Text) == "" {
+		return ErrNoMessage
+	}
+	if m.Theme == "" {
+		m.Theme = Themes[0]
+	}
+	for _, t := range Themes {
+		if m.Theme == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownTheme, m.Theme)
+}
+
+// Query parameters which render the message at MessagePath.
+func (m *Message) Query() url.Values {
+	q := url.Values{"text": {m.Text}}
+	if m.Markdown {
+		q.Set("format", "markdown")
+	}
+	if m.Theme != "" && m.Theme != Themes[0] {
+		q.Set("theme", m.Theme)
+	}
+	if !m.Until.IsZero() {
+		q.Set("until", m.Until.UTC().Format(time.RFC3339))
+	}
+	return q
+}
+
+// messageFromQuery is the inverse of Message.Query.
+func messageFromQuery(q url.Values) (*Message, error) {
+	m := &Message{
+		Text:     q.Get("text"),
+		Markdown: q.Get("format") == "markdown",
+		Theme:    q.Get("theme"),
+	}
+	if until := q.Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, fmt.Errorf("until %q is not an RFC 3339 time: %w", until, err)
+		}
+		m.Until = t
+	}
+	if err := m.Check(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// render Markdown without any raw HTML, so that a message can't run scripts on
+// a screen.
+func renderMarkdown(text string) template.HTML {
+	r := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags | blackfriday.SkipHTML | blackfriday.Safelink,
+	})
+	return template.HTML(blackfriday.Run([]byte(text),
+		blackfriday.WithRenderer(r),
+		blackfriday.WithExtensions(blackfriday.CommonExtensions)))
+}
+
+// MessageHandler renders the message described by the request's query, as
+// built by Message.Query, as a page for a screen to show.
+func MessageHandler(w http.ResponseWriter, r *http.Request) {
+	m, err := messageFromQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad message: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad message")
+		return
+	}
+	data := struct {
+		Body     interface{}
+		Theme    string
+		Markdown bool
+		Until    int64
+		Now      int64
+	}{
+		Body:     m.Text,
+		Theme:    m.Theme,
+		Markdown: m.Markdown,
+	}
+	if m.Markdown {
+		data.Body = renderMarkdown(m.Text)
+	}
+	if !m.Until.IsZero() {
+		data.Until = m.Until.UnixNano() / int64(time.Millisecond)
+		data.Now = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := messagePage.Execute(w, &data); err != nil {
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("rendering message failed")
+	}
+}
+
+// MessageURL at which a screen can show m, given the URL at which screens
+// reach the server.
+func MessageURL(base string, m *Message) string {
+	return strings.TrimSuffix(base, "/") + MessagePath + "?" + m.Query().Encode()
+}
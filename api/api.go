@@ -3,27 +3,48 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cfunkhouser/pijector"
+	"github.com/cfunkhouser/pijector/apps"
+	"github.com/cfunkhouser/pijector/content"
+	"github.com/cfunkhouser/pijector/layout"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 type v1ScreenHandler struct {
-	s pijector.Screen
+	s    pijector.Screen
+	opts *options
 }
 
 func (v *v1ScreenHandler) getShow(w http.ResponseWriter, r *http.Request) {
 	u := r.URL.Query().Get("target")
+	if name := r.URL.Query().Get("media"); name != "" && u == "" {
+		if u = v.mediaURL(w, r, name); u == "" {
+			return
+		}
+	}
+	if name := r.URL.Query().Get("app"); name != "" && u == "" {
+		if u = v.appURL(w, r, name); u == "" {
+			return
+		}
+	}
+	if name := r.URL.Query().Get("layout"); name != "" && u == "" {
+		if u = v.layoutURL(w, r, name); u == "" {
+			return
+		}
+	}
 	if u == "" {
 		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "target parameter is required")
+		fmt.Fprint(w, "target, media, app or layout parameter is required")
 		logrus.WithField("client", r.RemoteAddr).Info("bad request, no target")
 		return
 	}
@@ -37,7 +58,30 @@ func (v *v1ScreenHandler) getShow(w http.ResponseWriter, r *http.Request) {
 		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad target")
 		return
 	}
-	if err := v.s.Show(saneURL.String()); err != nil {
+	d, err := parseDuration(r.URL.Query().Get("duration"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "duration %q is not valid: %v", r.URL.Query().Get("duration"), err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad duration")
+		return
+	}
+	v.display(w, r, saneURL.String(), d)
+}
+
+// display u on the screen, for d if it isn't zero, and tell the client how the
+// screen is doing.
+func (v *v1ScreenHandler) display(w http.ResponseWriter, r *http.Request, u string, d time.Duration) {
+	show := v.s.Show
+	if d > 0 {
+		show = func(u string) error { return v.s.ShowFor(u, d) }
+	}
+	if err := show(u); err != nil {
+		if errors.Is(err, pijector.ErrNotAllowed) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "screen isn't allowed to show %q", u)
+			logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("show refused by navigation policy")
+			return
+		}
 		w.WriteHeader(http.StatusBadGateway)
 		fmt.Fprintf(w, "screen couldn't show %q: %v", u, err)
 		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("show failed")
@@ -46,6 +90,28 @@ func (v *v1ScreenHandler) getShow(w http.ResponseWriter, r *http.Request) {
 	v.getStat(w, r)
 }
 
+// parseDuration accepts either a Go duration string ("90s", "1h30m") or a
+// whole number of seconds. An empty string is a zero duration.
+func parseDuration(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, errors.New("must not be negative")
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, nil
+}
+
 func (v *v1ScreenHandler) getSnap(w http.ResponseWriter, r *http.Request) {
 	snap, err := v.s.Snap()
 	if err != nil {
@@ -109,19 +175,60 @@ func (v *v1ScreenHandler) getStat(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func v1HandleScreen(router *mux.Router, s pijector.Screen) {
+// writeJSON payload v to the client.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		// Not much else we can do at this point.
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("returning payload failed")
+	}
+}
+
+// notImplemented tells the client that the screen doesn't support what was
+// asked of it.
+func notImplemented(w http.ResponseWriter, r *http.Request, what string) {
+	w.WriteHeader(http.StatusNotImplemented)
+	fmt.Fprintf(w, "screen doesn't support %v", what)
+	logrus.WithField("client", r.RemoteAddr).Infof("bad request, %v not supported", what)
+}
+
+func v1HandleScreen(router *mux.Router, s pijector.Screen, o *options) {
 	r := router.PathPrefix(fmt.Sprintf("/screen/%v", s.ID())).Subrouter().StrictSlash(true)
 	api := &v1ScreenHandler{
-		s: s,
+		s:    s,
+		opts: o,
 	}
 	r.Methods(http.MethodGet).Path("/").HandlerFunc(api.getStat)
-	r.Methods(http.MethodGet).Path("/show").HandlerFunc(api.getShow)
+	r.Methods(http.MethodGet).Path("/show").HandlerFunc(api.audited("show", api.getShow))
+	r.Methods(http.MethodPost).Path("/message").HandlerFunc(api.audited("message", api.postMessage))
 	r.Methods(http.MethodGet).Path("/snap").HandlerFunc(api.getSnap)
 	r.Methods(http.MethodGet).Path("/stat").HandlerFunc(api.getStat)
+	r.Methods(http.MethodGet).Path("/inject").HandlerFunc(api.getInject)
+	r.Methods(http.MethodPost).Path("/inject").HandlerFunc(api.audited("inject", o.adminOnly(api.postInject)))
+	r.Methods(http.MethodDelete).Path("/inject/{rule}").HandlerFunc(api.audited("uninject", o.adminOnly(api.deleteInject)))
+	r.Methods(http.MethodGet).Path("/overlay").HandlerFunc(api.getOverlay)
+	r.Methods(http.MethodPost).Path("/overlay").HandlerFunc(api.audited("overlay", api.postOverlay))
+	r.Methods(http.MethodDelete).Path("/overlay/{overlay}").HandlerFunc(api.audited("remove_overlay", api.deleteOverlay))
+	r.Methods(http.MethodPost).Path("/identify").HandlerFunc(api.audited("identify", api.postIdentify))
+	r.Methods(http.MethodGet).Path("/emulation").HandlerFunc(api.getEmulation)
+	r.Methods(http.MethodPut).Path("/emulation").HandlerFunc(api.audited("emulation", api.putEmulation))
+	r.Methods(http.MethodPost).Path("/input").HandlerFunc(api.audited("input", api.postInput))
+	r.Methods(http.MethodGet).Path("/present").HandlerFunc(api.getPresent)
+	r.Methods(http.MethodPut).Path("/present/auto").HandlerFunc(api.audited("present_auto", api.putPresentAuto))
+	r.Methods(http.MethodPost).Path("/present/{action}").HandlerFunc(api.audited("present", api.postPresent))
+	r.Methods(http.MethodGet).Path("/logs").HandlerFunc(api.getLogs)
+	r.Methods(http.MethodGet).Path("/events").HandlerFunc(api.getEvents)
+	r.Methods(http.MethodGet).Path("/history").HandlerFunc(api.getHistory)
+	r.Methods(http.MethodGet).Path("/history/timelapse").HandlerFunc(api.getTimelapse)
+	r.Methods(http.MethodGet).Path("/history/{snap}").HandlerFunc(api.getHistorySnap)
+	r.Methods(http.MethodPost).Path("/history/back").HandlerFunc(api.audited("back", api.postHistoryBack))
+	r.Methods(http.MethodPost).Path("/history/restore/{entry}").HandlerFunc(api.audited("restore", api.postHistoryRestore))
+	r.Methods(http.MethodPost).Path("/eval").HandlerFunc(api.audited("eval", o.adminOnly(api.postEval)))
 }
 
 type v1 struct {
 	screens []pijector.Screen
+	opts    *options
 }
 
 type screensPayload struct {
@@ -147,20 +254,128 @@ func (v *v1) getScreens(w http.ResponseWriter, r *http.Request) {
 
 const V1APIPrefix = "/api/v1"
 
+type options struct {
+	AdminPassword string
+	Archive       *pijector.Archive
+	Plays         *pijector.PlayLog
+	Audit         *pijector.AuditLog
+	Media         *content.Library
+	Apps          *apps.Apps
+	Layouts       *layout.Layouts
+	// PublicURL at which screens reach content hosted by the server.
+	PublicURL string
+	MaxUpload int64
+}
+
+// Option configures the API.
+type Option func(*options)
+
+// WithAdminPassword required by admin-only endpoints, such as eval. Without
+// one, admin-only endpoints are disabled.
+func WithAdminPassword(password string) Option {
+	return func(o *options) {
+		o.AdminPassword = password
+	}
+}
+
+// WithArchive of snapshots, from which screen history and timelapses are
+// served.
+func WithArchive(a *pijector.Archive) Option {
+	return func(o *options) {
+		o.Archive = a
+	}
+}
+
+// WithPlayLog from which proof of play reports are served.
+func WithPlayLog(pl *pijector.PlayLog) Option {
+	return func(o *options) {
+		o.Plays = pl
+	}
+}
+
+// WithAuditLog in which every control action is recorded.
+func WithAuditLog(a *pijector.AuditLog) Option {
+	return func(o *options) {
+		o.Audit = a
+	}
+}
+
+// WithMediaLibrary to which media can be uploaded, and from which screens can
+// be told to show it.
+func WithMediaLibrary(lib *content.Library) Option {
+	return func(o *options) {
+		o.Media = lib
+	}
+}
+
+// WithApps to which app bundles can be uploaded, and which screens can be told
+// to show.
+func WithApps(a *apps.Apps) Option {
+	return func(o *options) {
+		o.Apps = a
+	}
+}
+
+// WithLayouts which can be managed through the API, and which screens can be
+// told to show.
+func WithLayouts(ls *layout.Layouts) Option {
+	return func(o *options) {
+		o.Layouts = ls
+	}
+}
+
+// WithPublicURL at which screens reach content hosted by the server, like
+// media and apps. Without one, screens are assumed to reach the server at the
+// address the API client used.
+func WithPublicURL(u string) Option {
+	return func(o *options) {
+		o.PublicURL = u
+	}
+}
+
+// WithMaxUpload size in bytes of media and app bundles. Defaults to 512MiB.
+func WithMaxUpload(n int64) Option {
+	return func(o *options) {
+		o.MaxUpload = n
+	}
+}
+
 // HandleV1 API at V1APIPrefix under the router.
-func HandleV1(router *mux.Router, screens []pijector.Screen) {
+func HandleV1(router *mux.Router, screens []pijector.Screen, opts ...Option) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	r := router.PathPrefix(V1APIPrefix).Subrouter().StrictSlash(true)
+	r.Use(countRequests)
 	for _, s := range screens {
-		v1HandleScreen(r, s)
+		v1HandleScreen(r, s, o)
 	}
-	r.Methods(http.MethodGet).Path("/screen").HandlerFunc((&v1{
+	api := &v1{
 		screens: screens,
-	}).getScreens)
+		opts:    o,
+	}
+	r.Methods(http.MethodGet).Path("/screen").HandlerFunc(api.getScreens)
+	r.Methods(http.MethodPost).Path("/identify").HandlerFunc(o.audited("identify", "", api.postIdentifyAll))
+	r.Methods(http.MethodGet).Path("/proof-of-play").HandlerFunc(api.getProofOfPlay)
+	r.Methods(http.MethodGet).Path("/audit").HandlerFunc(o.adminOnly(api.getAudit))
+	r.Methods(http.MethodGet).Path("/media").HandlerFunc(api.getMedia)
+	r.Methods(http.MethodPost).Path("/media").HandlerFunc(o.audited("upload", "", api.postMedia))
+	r.Methods(http.MethodDelete).Path("/media/{name}").HandlerFunc(o.audited("delete_media", "", api.deleteMedia))
+	r.Methods(http.MethodGet).Path("/apps").HandlerFunc(api.getApps)
+	r.Methods(http.MethodGet).Path("/apps/{name}").HandlerFunc(api.getApp)
+	r.Methods(http.MethodPost).Path("/apps/{name}").HandlerFunc(o.audited("upload_app", "", o.adminOnly(api.postApp)))
+	r.Methods(http.MethodPost).Path("/apps/{name}/rollback").HandlerFunc(o.audited("rollback_app", "", o.adminOnly(api.postAppRollback)))
+	r.Methods(http.MethodDelete).Path("/apps/{name}").HandlerFunc(o.audited("delete_app", "", o.adminOnly(api.deleteApp)))
+	r.Methods(http.MethodGet).Path("/layouts").HandlerFunc(api.getLayouts)
+	r.Methods(http.MethodGet).Path("/layouts/{name}").HandlerFunc(api.getLayout)
+	r.Methods(http.MethodPut).Path("/layouts/{name}").HandlerFunc(o.audited("layout", "", api.putLayout))
+	r.Methods(http.MethodDelete).Path("/layouts/{name}").HandlerFunc(o.audited("delete_layout", "", api.deleteLayout))
 }
 
 // New V1 Pijector API handler.
-func New(screens []pijector.Screen) http.Handler {
+func New(screens []pijector.Screen, opts ...Option) http.Handler {
 	r := mux.NewRouter()
-	HandleV1(r, screens)
+	HandleV1(r, screens, opts...)
 	return r
 }
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/cfunkhouser/pijector/apps"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type appsPayload struct {
+	Apps []apps.App `json:"apps"`
+}
+
+// apps hosted by the server, or nil after telling the client there aren't
+// any.
+func (o *options) apps(w http.ResponseWriter, r *http.Request) *apps.Apps {
+	if o.Apps == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, "apps aren't configured")
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, apps not configured")
+	}
+	return o.Apps
+}
+
+// appFailed tells the client why an app operation failed.
+func appFailed(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case tooLarge(err):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, apps.ErrNoSuchApp), errors.Is(err, apps.ErrNoSuchVersion):
+		status = http.StatusNotFound
+	case errors.Is(err, apps.ErrInvalidName), errors.Is(err, apps.ErrInvalidBundle):
+		status = http.StatusBadRequest
+	}
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "apps: %v", err)
+	logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("apps request failed")
+}
+
+func (v *v1) getApps(w http.ResponseWriter, r *http.Request) {
+	a := v.opts.apps(w, r)
+	if a == nil {
+		return
+	}
+	list, err := a.List()
+	if err != nil {
+		appFailed(w, r, err)
+		return
+	}
+	writeJSON(w, r, &appsPayload{Apps: list})
+}
+
+func (v *v1) getApp(w http.ResponseWriter, r *http.Request) {
+	a := v.opts.apps(w, r)
+	if a == nil {
+		return
+	}
+	app, err := a.Get(mux.Vars(r)["name"])
+	if err != nil {
+		appFailed(w, r, err)
+		return
+	}
+	writeJSON(w, r, app)
+}
+
+// postApp uploads a zip bundle as the new version of an app.
+func (v *v1) postApp(w http.ResponseWriter, r *http.Request) {
+	a := v.opts.apps(w, r)
+	if a == nil {
+		return
+	}
+	body, _, ok := v.opts.upload(w, r)
+	if !ok {
+		return
+	}
+	if c, ok := body.(io.Closer); ok {
+		defer c.Close()
+	}
+	app, err := a.Upload(mux.Vars(r)["name"], body)
+	if err != nil {
+		appFailed(w, r, err)
+		return
+	}
+	w.Header().Set("Location", app.URL)
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, app)
+}
+
+// postAppRollback makes the "version" parameter the current version of an
+// app, or if it is left out, the version before the current one.
+func (v *v1) postAppRollback(w http.ResponseWriter, r *http.Request) {
+	a := v.opts.apps(w, r)
+	if a == nil {
+		return
+	}
+	version := 0
+	if s := r.URL.Query().Get("version"); s != "" {
+		var err error
+		if version, err = strconv.Atoi(s); err != nil || version < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "version %q is not a version number", s)
+			logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad app version")
+			return
+		}
+	}
+	app, err := a.Rollback(mux.Vars(r)["name"], version)
+	if err != nil {
+		appFailed(w, r, err)
+		return
+	}
+	writeJSON(w, r, app)
+}
+
+func (v *v1) deleteApp(w http.ResponseWriter, r *http.Request) {
+	a := v.opts.apps(w, r)
+	if a == nil {
+		return
+	}
+	if err := a.Delete(mux.Vars(r)["name"]); err != nil {
+		appFailed(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// appURL at which a screen can show the app with name, or an empty string
+// after telling the client why there isn't one.
+func (v *v1ScreenHandler) appURL(w http.ResponseWriter, r *http.Request, name string) string {
+	a := v.opts.apps(w, r)
+	if a == nil {
+		return ""
+	}
+	app, err := a.Get(name)
+	if err != nil {
+		appFailed(w, r, err)
+		return ""
+	}
+	return v.opts.contentBase(r) + app.URL
+}
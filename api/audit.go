@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultAuditLimit = 100
+
+// clientIP of the request, without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// verifiedUserKey of the request context under which audited leaves room for
+// adminOnly to note the user name of a client which gave the admin password.
+type verifiedUserKey struct{}
+
+// verified notes that the client making r authenticated as user, for the audit
+// log.
+func verified(r *http.Request, user string) {
+	if who, ok := r.Context().Value(verifiedUserKey{}).(*string); ok {
+		*who = user
+	}
+}
+
+// audited wraps h so that every call to it is recorded in the audit log as
+// action on the handler's screen, if there is an audit log.
+func (v *v1ScreenHandler) audited(action string, h http.HandlerFunc) http.HandlerFunc {
+	return v.opts.audited(action, v.s.ID(), h)
+}
+
+// audited wraps h so that every call to it is recorded in the audit log as
+// action on screen, which may be empty for server-wide actions.
+func (o *options) audited(action, screen string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.Audit == nil {
+			h(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		// Only a user name vouched for by the admin password is recorded, since
+		// any client can claim to be anyone.
+		var user string
+		h(rec, r.WithContext(context.WithValue(r.Context(), verifiedUserKey{}, &user)))
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		e := pijector.AuditEntry{
+			Client: clientIP(r),
+			User:   user,
+			Action: action,
+			Screen: screen,
+			Target: r.URL.Query().Get("target"),
+			Status: status,
+			Result: pijector.AuditResult(status),
+		}
+		params := make(map[string]string)
+		for k, vals := range r.URL.Query() {
+			if k != "target" && len(vals) > 0 {
+				params[k] = vals[0]
+			}
+		}
+		for k, val := range mux.Vars(r) {
+			params[k] = val
+		}
+		if len(params) > 0 {
+			e.Params = params
+		}
+		o.Audit.Record(e)
+	}
+}
+
+type auditPayload struct {
+	Entries []pijector.AuditEntry `json:"entries"`
+}
+
+// getAudit returns audit log entries, most recent first, filtered by the
+// "screen", "action", "client", "user", "result", "from" and "to" query
+// parameters, up to "limit" entries.
+func (v *v1) getAudit(w http.ResponseWriter, r *http.Request) {
+	if v.opts.Audit == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, "audit log isn't configured")
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, audit log not configured")
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad time range: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad time range")
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultAuditLimit
+	if l := q.Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "limit %q is not a positive number", l)
+			logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad limit")
+			return
+		}
+	}
+	entries, err := v.opts.Audit.Query(pijector.AuditFilter{
+		Screen: q.Get("screen"),
+		Action: q.Get("action"),
+		Client: q.Get("client"),
+		User:   q.Get("user"),
+		Result: q.Get("result"),
+		From:   from,
+		To:     to,
+		Limit:  limit,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "couldn't read audit log: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("reading audit log failed")
+		return
+	}
+	if entries == nil {
+		entries = []pijector.AuditEntry{}
+	}
+	writeJSON(w, r, &auditPayload{Entries: entries})
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/sirupsen/logrus"
+)
+
+func (v *v1ScreenHandler) getEmulation(w http.ResponseWriter, r *http.Request) {
+	em, ok := v.s.(pijector.Emulator)
+	if !ok {
+		notImplemented(w, r, "emulation")
+		return
+	}
+	e, err := em.Emulation()
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "screen couldn't provide emulation: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("getting emulation failed")
+		return
+	}
+	writeJSON(w, r, e)
+}
+
+func (v *v1ScreenHandler) putEmulation(w http.ResponseWriter, r *http.Request) {
+	em, ok := v.s.(pijector.Emulator)
+	if !ok {
+		notImplemented(w, r, "emulation")
+		return
+	}
+	var e pijector.Emulation
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "emulation is not valid JSON: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad emulation")
+		return
+	}
+	if err := em.SetEmulation(e); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, pijector.ErrInvalidEmulation) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "screen couldn't set emulation: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("setting emulation failed")
+		return
+	}
+	v.getEmulation(w, r)
+}
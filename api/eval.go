@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultEvalTimeout = 5 * time.Second
+	maxEvalTimeout     = time.Minute
+)
+
+// adminOnly wraps h so that it requires the admin password, sent as the
+// password of HTTP basic authentication.
+func (o *options) adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		want := o.AdminPassword
+		if want == "" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "admin endpoints are disabled; set an admin password to enable them")
+			logrus.WithField("client", r.RemoteAddr).Warn("refused admin request, no admin password set")
+			return
+		}
+		user, got, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pijector"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, "admin password required")
+			logrus.WithField("client", r.RemoteAddr).Warn("refused admin request, bad password")
+			return
+		}
+		verified(r, user)
+		h(w, r)
+	}
+}
+
+type evalRequest struct {
+	Expression string `json:"expression"`
+}
+
+func (v *v1ScreenHandler) postEval(w http.ResponseWriter, r *http.Request) {
+	ev, ok := v.s.(pijector.Evaluator)
+	if !ok {
+		notImplemented(w, r, "eval")
+		return
+	}
+	var req evalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Expression == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `body must be JSON like {"expression": "document.readyState"}`)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad expression")
+		return
+	}
+	timeout, err := parseDuration(r.URL.Query().Get("timeout"))
+	if err != nil || timeout > maxEvalTimeout {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "timeout must be a duration up to %v", maxEvalTimeout)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad timeout")
+		return
+	}
+	if timeout == 0 {
+		timeout = defaultEvalTimeout
+	}
+	res, err := ev.Eval(req.Expression, timeout)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, pijector.ErrEvalTimeout) {
+			status = http.StatusGatewayTimeout
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "screen couldn't evaluate expression: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("evaluating expression failed")
+		return
+	}
+	writeJSON(w, r, res)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/sirupsen/logrus"
+)
+
+// getEvents streams the Screen's events to the client as Server-Sent Events,
+// until the client goes away.
+func (v *v1ScreenHandler) getEvents(w http.ResponseWriter, r *http.Request) {
+	es, ok := v.s.(pijector.EventSource)
+	if !ok {
+		notImplemented(w, r, "events")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "streaming is unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for e := range es.Events(r.Context()) {
+		data, err := json.Marshal(&e)
+		if err != nil {
+			logrus.WithError(err).WithField("client", r.RemoteAddr).Error("encoding event failed")
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "event: %v\ndata: %s\n\n", e.Type, data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
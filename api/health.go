@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultReadinessTimeout = 5 * time.Second
+
+var errCheckTimeout = errors.New("timed out")
+
+// ReadinessPolicy decides whether the server is ready, based on which of its
+// screens are up.
+type ReadinessPolicy struct {
+	// MinUp is how many screens must be up for the server to be ready. If zero,
+	// every screen must be.
+	MinUp int
+	// Timeout for checking each screen. Defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+type screenHealth struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Up    bool   `json:"up"`
+	URL   string `json:"url,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type readinessPayload struct {
+	Ready   bool           `json:"ready"`
+	Up      int            `json:"up"`
+	MinUp   int            `json:"min_up"`
+	Screens []screenHealth `json:"screens"`
+}
+
+type health struct {
+	screens []pijector.Screen
+	policy  ReadinessPolicy
+}
+
+// check s, which is up if it answers a status check in time. For a local
+// screen this means its Chromium debugger is reachable, and for a remote one
+// that its server is.
+func (h *health) check(s pijector.Screen) screenHealth {
+	sh := screenHealth{ID: s.ID(), Name: s.Name()}
+	type result struct {
+		stat pijector.ScreenStatus
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stat, err := s.Stat()
+		done <- result{stat, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			sh.Error = r.err.Error()
+			return sh
+		}
+		sh.Up = true
+		sh.URL = r.stat.URL
+	case <-time.After(h.policy.Timeout):
+		sh.Error = errCheckTimeout.Error()
+	}
+	return sh
+}
+
+func (h *health) getHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "ok")
+}
+
+func (h *health) getReadyz(w http.ResponseWriter, r *http.Request) {
+	p := &readinessPayload{
+		MinUp:   h.policy.MinUp,
+		Screens: make([]screenHealth, len(h.screens)),
+	}
+	if p.MinUp <= 0 || p.MinUp > len(h.screens) {
+		p.MinUp = len(h.screens)
+	}
+	var wg sync.WaitGroup
+	for i, s := range h.screens {
+		wg.Add(1)
+		go func(i int, s pijector.Screen) {
+			defer wg.Done()
+			p.Screens[i] = h.check(s)
+		}(i, s)
+	}
+	wg.Wait()
+	for _, sh := range p.Screens {
+		if sh.Up {
+			p.Up++
+		}
+	}
+	p.Ready = p.Up >= p.MinUp
+	if !p.Ready {
+		logrus.WithFields(logrus.Fields{
+			"client": r.RemoteAddr,
+			"up":     p.Up,
+			"min_up": p.MinUp,
+		}).Warn("not ready")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, r, p)
+}
+
+// HandleHealth serves /healthz, which answers as long as the process does, and
+// /readyz, which checks every screen and answers 503 Service Unavailable unless
+// enough of them are up to satisfy the policy.
+func HandleHealth(router *mux.Router, screens []pijector.Screen, policy ReadinessPolicy) {
+	if policy.Timeout <= 0 {
+		policy.Timeout = defaultReadinessTimeout
+	}
+	h := &health{
+		screens: screens,
+		policy:  policy,
+	}
+	router.Methods(http.MethodGet).Path("/healthz").HandlerFunc(h.getHealthz)
+	router.Methods(http.MethodGet).Path("/readyz").HandlerFunc(h.getReadyz)
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTimelapseFPS = 10
+	maxTimelapseFPS     = 30
+)
+
+// parseTimeRange from the "from" and "to" query parameters, which are
+// RFC 3339 timestamps. Either may be left out to leave that end open.
+func parseTimeRange(r *http.Request) (from, to time.Time, err error) {
+	q := r.URL.Query()
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		to, err = time.Parse(time.RFC3339, v)
+	}
+	return
+}
+
+// archive for the handler's screen, or nil after telling the client there
+// isn't one.
+func (v *v1ScreenHandler) archive(w http.ResponseWriter, r *http.Request) *pijector.Archive {
+	if v.opts.Archive == nil {
+		notImplemented(w, r, "history")
+	}
+	return v.opts.Archive
+}
+
+// historyPayload merges a screen's navigation history with its archived
+// snapshots. Each is left out only when the screen doesn't remember its
+// navigation, or there is no archive, or it wasn't asked for; otherwise it is
+// present even if empty.
+type historyPayload struct {
+	Navigation *[]pijector.HistoryEntry     `json:"navigation,omitempty"`
+	Snapshots  *[]pijector.ArchivedSnapshot `json:"snapshots,omitempty"`
+}
+
+// getHistory of the URLs shown on the screen, if it remembers them, and of its
+// archived snapshots, if there is an archive. The "only" parameter limits it
+// to "navigation" or "snapshots".
+func (v *v1ScreenHandler) getHistory(w http.ResponseWriter, r *http.Request) {
+	nav, remembers := v.s.(pijector.Navigator)
+	a := v.opts.Archive
+	switch only := r.URL.Query().Get("only"); only {
+	case "":
+	case "navigation":
+		a = nil
+	case "snapshots":
+		remembers = false
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "only %q is neither navigation nor snapshots", only)
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, bad only")
+		return
+	}
+	if !remembers && a == nil {
+		notImplemented(w, r, "history")
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad time range: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad time range")
+		return
+	}
+	var p historyPayload
+	if remembers {
+		entries, err := nav.NavigationHistory()
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprintf(w, "screen couldn't provide its history: %v", err)
+			logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("listing navigation history failed")
+			return
+		}
+		shown := make([]pijector.HistoryEntry, 0, len(entries))
+		for _, e := range entries {
+			if (from.IsZero() || !e.Time.Before(from)) && (to.IsZero() || !e.Time.After(to)) {
+				shown = append(shown, e)
+			}
+		}
+		p.Navigation = &shown
+	}
+	if a != nil {
+		snaps, err := a.History(v.s.ID(), from, to)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "couldn't list history: %v", err)
+			logrus.WithError(err).WithField("client", r.RemoteAddr).Error("listing history failed")
+			return
+		}
+		if snaps == nil {
+			snaps = []pijector.ArchivedSnapshot{}
+		}
+		p.Snapshots = &snaps
+	}
+	writeJSON(w, r, &p)
+}
+
+// navigator for the handler's screen, or nil after telling the client it
+// doesn't remember its history.
+func (v *v1ScreenHandler) navigator(w http.ResponseWriter, r *http.Request) pijector.Navigator {
+	nav, ok := v.s.(pijector.Navigator)
+	if !ok {
+		notImplemented(w, r, "navigation history")
+	}
+	return nav
+}
+
+// restored tells the client how restoring a history entry went.
+func (v *v1ScreenHandler) restored(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, pijector.ErrNoSuchEntry):
+			status = http.StatusNotFound
+		case errors.Is(err, pijector.ErrNotAllowed):
+			status = http.StatusForbidden
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "screen couldn't restore history: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("restoring history failed")
+		return
+	}
+	v.getStat(w, r)
+}
+
+func (v *v1ScreenHandler) postHistoryBack(w http.ResponseWriter, r *http.Request) {
+	if nav := v.navigator(w, r); nav != nil {
+		v.restored(w, r, nav.Back())
+	}
+}
+
+func (v *v1ScreenHandler) postHistoryRestore(w http.ResponseWriter, r *http.Request) {
+	nav := v.navigator(w, r)
+	if nav == nil {
+		return
+	}
+	n, err := strconv.Atoi(mux.Vars(r)["entry"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "history entry %q is not a number", mux.Vars(r)["entry"])
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad history entry")
+		return
+	}
+	v.restored(w, r, nav.Restore(n))
+}
+
+func (v *v1ScreenHandler) getHistorySnap(w http.ResponseWriter, r *http.Request) {
+	a := v.archive(w, r)
+	if a == nil {
+		return
+	}
+	snap, err := a.Open(v.s.ID(), mux.Vars(r)["snap"])
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, pijector.ErrNoSuchSnapshot) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "couldn't open snapshot: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("opening snapshot failed")
+		return
+	}
+	defer snap.Close()
+	w.Header().Set("Content-Type", "image/jpeg")
+	if _, err := io.Copy(w, snap); err != nil {
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("returning snapshot failed")
+	}
+}
+
+func (v *v1ScreenHandler) getTimelapse(w http.ResponseWriter, r *http.Request) {
+	a := v.archive(w, r)
+	if a == nil {
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad time range: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad time range")
+		return
+	}
+	fps := defaultTimelapseFPS
+	if v := r.URL.Query().Get("fps"); v != "" {
+		if fps, err = strconv.Atoi(v); err != nil || fps < 1 || fps > maxTimelapseFPS {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "fps must be a whole number from 1 to %v", maxTimelapseFPS)
+			logrus.WithField("client", r.RemoteAddr).Info("bad request, bad fps")
+			return
+		}
+	}
+	delay := time.Second / time.Duration(fps)
+	id := v.s.ID()
+	snaps, err := a.History(id, from, to)
+	if err == nil && len(snaps) == 0 {
+		err = fmt.Errorf("%w: none in that time range", pijector.ErrNoSuchSnapshot)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, pijector.ErrNoSuchSnapshot) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "couldn't build timelapse: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("building timelapse failed")
+		return
+	}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "gif":
+		// Build the whole GIF first, so a failure can still be reported.
+		var buf bytes.Buffer
+		if err := a.GIF(&buf, id, from, to, delay); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "couldn't build timelapse: %v", err)
+			logrus.WithError(err).WithField("client", r.RemoteAddr).Error("building timelapse failed")
+			return
+		}
+		w.Header().Set("Content-Type", "image/gif")
+		_, err = buf.WriteTo(w)
+	case "mjpeg":
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mw.Boundary())
+		var flush func()
+		if f, ok := w.(http.Flusher); ok {
+			flush = f.Flush
+		}
+		err = a.MJPEG(mw, flush, id, from, to, delay, r.Context().Done())
+		if err == nil {
+			err = mw.Close()
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "unknown format %q; use gif or mjpeg", format)
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, bad timelapse format")
+		return
+	}
+	if err != nil {
+		// Headers are gone already, so all that's left is to note it.
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("returning timelapse failed")
+	}
+}
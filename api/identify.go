@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/sirupsen/logrus"
+)
+
+var errCantIdentify = errors.New("screen can't identify itself")
+
+// identifyRequest says how screens should identify themselves.
+type identifyRequest struct {
+	d time.Duration
+	// qr is the URL to encode in a QR code, if any. If admin is set, it is the
+	// admin UI, and the ID of the screen being identified is appended.
+	qr    string
+	admin bool
+}
+
+// identifyParams from the request: how long to identify screens for, from the
+// "duration" parameter, and whether to show a QR code, from the "qr"
+// parameter. It is either "true", for the admin UI of the screen being
+// identified, or a URL to encode. Returns false after telling the client
+// what's wrong with them.
+func (o *options) identifyParams(w http.ResponseWriter, r *http.Request) (*identifyRequest, bool) {
+	q := r.URL.Query()
+	d, err := parseDuration(q.Get("duration"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "duration %q is not valid: %v", q.Get("duration"), err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad duration")
+		return nil, false
+	}
+	req := &identifyRequest{d: d}
+	switch qr := q.Get("qr"); {
+	case qr == "", qr == "false":
+	case qr == "true":
+		req.qr = o.contentBase(r) + "/admin?screen="
+		req.admin = true
+	case strings.HasPrefix(qr, "http://"), strings.HasPrefix(qr, "https://"):
+		req.qr = qr
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "qr %q is neither true nor a URL", qr)
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, bad qr")
+		return nil, false
+	}
+	return req, true
+}
+
+// identify s as asked.
+func (req *identifyRequest) identify(s pijector.Screen) error {
+	id, ok := s.(pijector.Identifier)
+	if !ok {
+		return errCantIdentify
+	}
+	qr := req.qr
+	if req.admin {
+		qr += url.QueryEscape(s.ID())
+	}
+	return id.Identify(req.d, qr)
+}
+
+func (v *v1ScreenHandler) postIdentify(w http.ResponseWriter, r *http.Request) {
+	if _, ok := v.s.(pijector.Identifier); !ok {
+		notImplemented(w, r, "identification")
+		return
+	}
+	req, ok := v.opts.identifyParams(w, r)
+	if !ok {
+		return
+	}
+	if err := req.identify(v.s); err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "screen couldn't identify itself: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("identify failed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type identifiedScreen struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type identifyPayload struct {
+	Screens []identifiedScreen `json:"screens"`
+}
+
+// postIdentifyAll identifies every screen at once, reporting which of them
+// couldn't.
+func (v *v1) postIdentifyAll(w http.ResponseWriter, r *http.Request) {
+	req, ok := v.opts.identifyParams(w, r)
+	if !ok {
+		return
+	}
+	p := &identifyPayload{Screens: make([]identifiedScreen, len(v.screens))}
+	var wg sync.WaitGroup
+	for i, s := range v.screens {
+		wg.Add(1)
+		go func(i int, s pijector.Screen) {
+			defer wg.Done()
+			is := identifiedScreen{ID: s.ID(), Name: s.Name()}
+			if err := req.identify(s); err != nil {
+				is.Error = err.Error()
+				logrus.WithError(err).WithField("screen", s.ID()).Warn("identify failed")
+			}
+			p.Screens[i] = is
+		}(i, s)
+	}
+	wg.Wait()
+	writeJSON(w, r, p)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func (v *v1ScreenHandler) getInject(w http.ResponseWriter, r *http.Request) {
+	inj, ok := v.s.(pijector.Injector)
+	if !ok {
+		notImplemented(w, r, "injection rules")
+		return
+	}
+	rules, err := inj.InjectionRules()
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "screen couldn't provide injection rules: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("listing injection rules failed")
+		return
+	}
+	if rules == nil {
+		rules = []pijector.InjectionRule{}
+	}
+	writeJSON(w, r, rules)
+}
+
+func (v *v1ScreenHandler) postInject(w http.ResponseWriter, r *http.Request) {
+	inj, ok := v.s.(pijector.Injector)
+	if !ok {
+		notImplemented(w, r, "injection rules")
+		return
+	}
+	var rule pijector.InjectionRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "injection rule is not valid JSON: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad injection rule")
+		return
+	}
+	added, err := inj.AddInjectionRule(rule)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "screen couldn't add injection rule: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("adding injection rule failed")
+		return
+	}
+	writeJSON(w, r, added)
+}
+
+func (v *v1ScreenHandler) deleteInject(w http.ResponseWriter, r *http.Request) {
+	inj, ok := v.s.(pijector.Injector)
+	if !ok {
+		notImplemented(w, r, "injection rules")
+		return
+	}
+	id := mux.Vars(r)["rule"]
+	if err := inj.RemoveInjectionRule(id); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, pijector.ErrNoSuchRule) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "screen couldn't remove injection rule: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("removing injection rule failed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
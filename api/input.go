@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/sirupsen/logrus"
+)
+
+func (v *v1ScreenHandler) postInput(w http.ResponseWriter, r *http.Request) {
+	inp, ok := v.s.(pijector.Inputter)
+	if !ok {
+		notImplemented(w, r, "input")
+		return
+	}
+	var in pijector.Input
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "input is not valid JSON: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad input")
+		return
+	}
+	if err := inp.SendInput(in); err != nil {
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, pijector.ErrInvalidInput):
+			status = http.StatusBadRequest
+		case errors.Is(err, pijector.ErrNoSuchElement):
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "screen couldn't send input: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("sending input failed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cfunkhouser/pijector/layout"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type layoutsPayload struct {
+	Layouts   []layout.Layout `json:"layouts"`
+	Templates []string        `json:"templates"`
+}
+
+// layouts hosted by the server, or nil after telling the client there aren't
+// any.
+func (o *options) layouts(w http.ResponseWriter, r *http.Request) *layout.Layouts {
+	if o.Layouts == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, "layouts aren't configured")
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, layouts not configured")
+	}
+	return o.Layouts
+}
+
+// layoutFailed tells the client why a layout operation failed.
+func layoutFailed(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, layout.ErrNoSuchLayout):
+		status = http.StatusNotFound
+	case errors.Is(err, layout.ErrInvalidName), errors.Is(err, layout.ErrInvalidLayout):
+		status = http.StatusBadRequest
+	}
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "layouts: %v", err)
+	logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("layouts request failed")
+}
+
+func (v *v1) getLayouts(w http.ResponseWriter, r *http.Request) {
+	ls := v.opts.layouts(w, r)
+	if ls == nil {
+		return
+	}
+	list, err := ls.List()
+	if err != nil {
+		layoutFailed(w, r, err)
+		return
+	}
+	writeJSON(w, r, &layoutsPayload{Layouts: list, Templates: layout.Templates()})
+}
+
+func (v *v1) getLayout(w http.ResponseWriter, r *http.Request) {
+	ls := v.opts.layouts(w, r)
+	if ls == nil {
+		return
+	}
+	l, err := ls.Get(mux.Vars(r)["name"])
+	if err != nil {
+		layoutFailed(w, r, err)
+		return
+	}
+	writeJSON(w, r, l)
+}
+
+// putLayout saves the layout in the JSON body under the name in the path,
+// replacing any layout already saved under it.
+func (v *v1) putLayout(w http.ResponseWriter, r *http.Request) {
+	ls := v.opts.layouts(w, r)
+	if ls == nil {
+		return
+	}
+	var l layout.Layout
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "layout is not valid JSON: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad layout")
+		return
+	}
+	l.Name = mux.Vars(r)["name"]
+	saved, err := ls.Save(l)
+	if err != nil {
+		layoutFailed(w, r, err)
+		return
+	}
+	writeJSON(w, r, saved)
+}
+
+func (v *v1) deleteLayout(w http.ResponseWriter, r *http.Request) {
+	ls := v.opts.layouts(w, r)
+	if ls == nil {
+		return
+	}
+	if err := ls.Delete(mux.Vars(r)["name"]); err != nil {
+		layoutFailed(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// layoutURL at which a screen can show the layout with name, or an empty
+// string after telling the client why there isn't one.
+func (v *v1ScreenHandler) layoutURL(w http.ResponseWriter, r *http.Request, name string) string {
+	ls := v.opts.layouts(w, r)
+	if ls == nil {
+		return ""
+	}
+	l, err := ls.Get(name)
+	if err != nil {
+		layoutFailed(w, r, err)
+		return ""
+	}
+	return v.opts.contentBase(r) + l.URL
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/sirupsen/logrus"
+)
+
+func (v *v1ScreenHandler) getLogs(w http.ResponseWriter, r *http.Request) {
+	pl, ok := v.s.(pijector.PageLogger)
+	if !ok {
+		notImplemented(w, r, "logs")
+		return
+	}
+	logs, err := pl.Logs()
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "screen couldn't provide logs: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("getting logs failed")
+		return
+	}
+	writeJSON(w, r, logs)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cfunkhouser/pijector/content"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultMaxUpload = 512 << 20
+
+type mediaPayload struct {
+	Media []content.Item `json:"media"`
+}
+
+// media library, or nil after telling the client there isn't one.
+func (o *options) media(w http.ResponseWriter, r *http.Request) *content.Library {
+	if o.Media == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, "media library isn't configured")
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, media library not configured")
+	}
+	return o.Media
+}
+
+// tooLarge is set if err came from reading an upload beyond the limit put on
+// it by upload. The error has no type of its own to check for.
+func tooLarge(err error) bool {
+	return strings.Contains(err.Error(), "http: request body too large")
+}
+
+// mediaFailed tells the client why a media library operation failed.
+func mediaFailed(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case tooLarge(err):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, content.ErrNoSuchMedia):
+		status = http.StatusNotFound
+	case errors.Is(err, content.ErrInvalidName):
+		status = http.StatusBadRequest
+	case errors.Is(err, content.ErrUnsupportedMedia):
+		status = http.StatusUnsupportedMediaType
+	}
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "media library: %v", err)
+	logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("media library request failed")
+}
+
+func (v *v1) getMedia(w http.ResponseWriter, r *http.Request) {
+	lib := v.opts.media(w, r)
+	if lib == nil {
+		return
+	}
+	items, err := lib.List()
+	if err != nil {
+		mediaFailed(w, r, err)
+		return
+	}
+	writeJSON(w, r, &mediaPayload{Media: items})
+}
+
+// postMedia stores an upload in the library. It is either a multipart form
+// with the file in its "file" field, or the file itself as the body, named by
+// the "name" parameter.
+func (v *v1) postMedia(w http.ResponseWriter, r *http.Request) {
+	lib := v.opts.media(w, r)
+	if lib == nil {
+		return
+	}
+	body, name, ok := v.opts.upload(w, r)
+	if !ok {
+		return
+	}
+	if c, ok := body.(io.Closer); ok {
+		defer c.Close()
+	}
+	if name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "upload needs a name")
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, upload has no name")
+		return
+	}
+	it, err := lib.Save(name, body)
+	if err != nil {
+		mediaFailed(w, r, err)
+		return
+	}
+	w.Header().Set("Location", it.FileURL)
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, r, it)
+}
+
+// upload in the request, which is either a multipart form with the file in its
+// "file" field, or the file itself as the body. The name is that given by the
+// "name" parameter, if any, or else the uploaded file's. Returns false after
+// telling the client what's wrong with the upload.
+func (o *options) upload(w http.ResponseWriter, r *http.Request) (io.Reader, string, bool) {
+	maxUpload := o.MaxUpload
+	if maxUpload <= 0 {
+		maxUpload = defaultMaxUpload
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+	name := r.URL.Query().Get("name")
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, name, true
+	}
+	f, header, err := r.FormFile("file")
+	if err != nil && tooLarge(err) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprintf(w, "upload is larger than %v bytes", maxUpload)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, upload too large")
+		return nil, "", false
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "upload needs a file field: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, no file uploaded")
+		return nil, "", false
+	}
+	if name == "" {
+		name = header.Filename
+	}
+	return f, name, true
+}
+
+func (v *v1) deleteMedia(w http.ResponseWriter, r *http.Request) {
+	lib := v.opts.media(w, r)
+	if lib == nil {
+		return
+	}
+	if err := lib.Delete(mux.Vars(r)["name"]); err != nil {
+		mediaFailed(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// contentBase is the URL, without a trailing slash, at which screens reach
+// content hosted by the server. Unless the server is configured with one, it
+// is assumed to be reachable by screens at the address the client used.
+func (o *options) contentBase(r *http.Request) string {
+	if o.PublicURL != "" {
+		return strings.TrimSuffix(o.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%v://%v", scheme, r.Host)
+}
+
+// mediaURL at which a screen can view the media item with name, or an empty
+// string after telling the client why there isn't one.
+func (v *v1ScreenHandler) mediaURL(w http.ResponseWriter, r *http.Request, name string) string {
+	lib := v.opts.media(w, r)
+	if lib == nil {
+		return ""
+	}
+	it, err := lib.Get(name)
+	if err != nil {
+		mediaFailed(w, r, err)
+		return ""
+	}
+	u := v.opts.contentBase(r) + it.ViewURL
+	if fit := r.URL.Query().Get("fit"); fit != "" {
+		u += "?fit=" + url.QueryEscape(fit)
+	}
+	return u
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cfunkhouser/pijector/admin"
+	"github.com/sirupsen/logrus"
+)
+
+// parseCountdown accepts either an RFC 3339 time at which a countdown ends, or
+// how long from now it should run, as accepted by parseDuration. An empty
+// string is no countdown.
+func parseCountdown(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	d, err := parseDuration(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(d), nil
+}
+
+// postMessage shows a message rendered by the server, rather than a page of
+// its own. The message is the "text" parameter, which is Markdown if "format"
+// is "markdown", shown in "theme" with an optional "countdown" below it. The
+// message is shown for "duration" if there is one, or else until something
+// else is shown.
+func (v *v1ScreenHandler) postMessage(w http.ResponseWriter, r *http.Request) {
+	countdown, err := parseCountdown(r.FormValue("countdown"), time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "countdown %q is neither a time nor a duration: %v", r.FormValue("countdown"), err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad countdown")
+		return
+	}
+	d, err := parseDuration(r.FormValue("duration"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "duration %q is not valid: %v", r.FormValue("duration"), err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad duration")
+		return
+	}
+	format := r.FormValue("format")
+	if format != "" && format != "text" && format != "markdown" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "format %q is neither text nor markdown", format)
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, bad message format")
+		return
+	}
+	m := &admin.Message{
+		Text:     r.FormValue("text"),
+		Markdown: format == "markdown",
+		Theme:    r.FormValue("theme"),
+		Until:    countdown,
+	}
+	if err := m.Check(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad message: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad message")
+		return
+	}
+	v.display(w, r, admin.MessageURL(v.opts.contentBase(r), m), d)
+}
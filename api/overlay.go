@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func (v *v1ScreenHandler) getOverlay(w http.ResponseWriter, r *http.Request) {
+	ov, ok := v.s.(pijector.Overlayer)
+	if !ok {
+		notImplemented(w, r, "overlays")
+		return
+	}
+	shown, err := ov.Overlays()
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "screen couldn't provide overlays: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("listing overlays failed")
+		return
+	}
+	if shown == nil {
+		shown = []pijector.Overlay{}
+	}
+	writeJSON(w, r, shown)
+}
+
+// postOverlay adds the overlay in the JSON body to the screen. An optional
+// "duration" parameter sets when it expires.
+func (v *v1ScreenHandler) postOverlay(w http.ResponseWriter, r *http.Request) {
+	ov, ok := v.s.(pijector.Overlayer)
+	if !ok {
+		notImplemented(w, r, "overlays")
+		return
+	}
+	var o pijector.Overlay
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "overlay is not valid JSON: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad overlay")
+		return
+	}
+	d, err := parseDuration(r.URL.Query().Get("duration"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "duration %q is not valid: %v", r.URL.Query().Get("duration"), err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad duration")
+		return
+	}
+	if d > 0 {
+		expires := time.Now().Add(d)
+		o.Expires = &expires
+	}
+	added, err := ov.AddOverlay(o)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, pijector.ErrBadOverlay) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "screen couldn't add overlay: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("adding overlay failed")
+		return
+	}
+	writeJSON(w, r, added)
+}
+
+func (v *v1ScreenHandler) deleteOverlay(w http.ResponseWriter, r *http.Request) {
+	ov, ok := v.s.(pijector.Overlayer)
+	if !ok {
+		notImplemented(w, r, "overlays")
+		return
+	}
+	id := mux.Vars(r)["overlay"]
+	if err := ov.RemoveOverlay(id); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, pijector.ErrNoSuchOverlay) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "screen couldn't remove overlay: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("removing overlay failed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/sirupsen/logrus"
+)
+
+type playsPayload struct {
+	Plays []pijector.PlayRecord `json:"plays"`
+}
+
+type playTotalsPayload struct {
+	From   *time.Time           `json:"from,omitempty"`
+	To     *time.Time           `json:"to,omitempty"`
+	Group  string               `json:"group"`
+	Totals []pijector.PlayTotal `json:"totals"`
+}
+
+// csvTime formats t for a CSV report, leaving it empty if it's zero.
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// csvEnd of a play, which is empty while it's still going.
+func csvEnd(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return csvTime(*t)
+}
+
+func writeCSV(w http.ResponseWriter, r *http.Request, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="proof-of-play.csv"`)
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		// Not much else we can do at this point.
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("returning report failed")
+	}
+}
+
+// getProofOfPlay reports what the screens displayed between "from" and "to".
+// With "group" set to "url" or "screen" it totals the time displayed, otherwise
+// it lists every play. With "format" set to "csv" the report is CSV rather than
+// JSON.
+func (v *v1) getProofOfPlay(w http.ResponseWriter, r *http.Request) {
+	if v.opts.Plays == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, "proof of play isn't configured")
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, proof of play not configured")
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad time range: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad time range")
+		return
+	}
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "format %q is not json or csv", format)
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, bad format")
+		return
+	}
+	group := q.Get("group")
+	switch group {
+	case "":
+		v.writePlays(w, r, from, to, format == "csv")
+	case "url", "screen":
+		v.writePlayTotals(w, r, from, to, group, format == "csv")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "group %q is not url or screen", group)
+		logrus.WithField("client", r.RemoteAddr).Info("bad request, bad group")
+	}
+}
+
+func (v *v1) writePlays(w http.ResponseWriter, r *http.Request, from, to time.Time, asCSV bool) {
+	plays, err := v.opts.Plays.Records(from, to)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "couldn't read plays: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("reading plays failed")
+		return
+	}
+	if !asCSV {
+		writeJSON(w, r, &playsPayload{Plays: plays})
+		return
+	}
+	rows := [][]string{{"screen", "screen_name", "url", "source", "start", "end", "loaded", "http_status", "error"}}
+	for _, p := range plays {
+		status := ""
+		if p.HTTPStatus != 0 {
+			status = strconv.Itoa(p.HTTPStatus)
+		}
+		rows = append(rows, []string{
+			p.Screen, p.ScreenName, p.URL, string(p.Source),
+			csvTime(p.Start), csvEnd(p.End),
+			strconv.FormatBool(p.Loaded), status, p.Error,
+		})
+	}
+	writeCSV(w, r, rows)
+}
+
+func (v *v1) writePlayTotals(w http.ResponseWriter, r *http.Request, from, to time.Time, group string, asCSV bool) {
+	totals, err := v.opts.Plays.PlayReport(from, to, group == "screen")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "couldn't read plays: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("reading plays failed")
+		return
+	}
+	if !asCSV {
+		p := &playTotalsPayload{Group: group, Totals: totals}
+		if !from.IsZero() {
+			p.From = &from
+		}
+		if !to.IsZero() {
+			p.To = &to
+		}
+		writeJSON(w, r, p)
+		return
+	}
+	rows := [][]string{{group, "name", "plays", "failed", "seconds"}}
+	for _, t := range totals {
+		rows = append(rows, []string{
+			t.Key, t.Name, strconv.Itoa(t.Plays), strconv.Itoa(t.Failed),
+			strconv.FormatFloat(t.Seconds, 'f', 0, 64),
+		})
+	}
+	writeCSV(w, r, rows)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cfunkhouser/pijector"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func (v *v1ScreenHandler) getPresent(w http.ResponseWriter, r *http.Request) {
+	p, ok := v.s.(pijector.Presenter)
+	if !ok {
+		notImplemented(w, r, "presenting")
+		return
+	}
+	st, err := p.Slide()
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "screen couldn't provide slide status: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("getting slide status failed")
+		return
+	}
+	writeJSON(w, r, st)
+}
+
+func (v *v1ScreenHandler) postPresent(w http.ResponseWriter, r *http.Request) {
+	p, ok := v.s.(pijector.Presenter)
+	if !ok {
+		notImplemented(w, r, "presenting")
+		return
+	}
+	action, err := pijector.ParseSlideAction(mux.Vars(r)["action"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad slide action: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad slide action")
+		return
+	}
+	st, err := p.Present(action)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, pijector.ErrInvalidSlideAction) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "screen couldn't change slide: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("changing slide failed")
+		return
+	}
+	writeJSON(w, r, st)
+}
+
+func (v *v1ScreenHandler) putPresentAuto(w http.ResponseWriter, r *http.Request) {
+	p, ok := v.s.(pijector.Presenter)
+	if !ok {
+		notImplemented(w, r, "presenting")
+		return
+	}
+	d, err := parseDuration(r.FormValue("interval"))
+	if err == nil && d > 0 && d < pijector.MinAutoAdvance {
+		err = fmt.Errorf("must be 0 or at least %v", pijector.MinAutoAdvance)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "bad interval: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Info("bad request, bad interval")
+		return
+	}
+	st, err := p.AutoAdvance(d)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "screen couldn't auto advance: %v", err)
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Warn("setting auto advance failed")
+		return
+	}
+	writeJSON(w, r, st)
+}
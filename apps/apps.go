@@ -0,0 +1,412 @@
+// Package apps hosts static web apps for pijector screens, uploaded as zip
+// bundles and kept in versions which can be rolled back.
+package apps
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoSuchApp is returned when asked for an app which hasn't been uploaded.
+	ErrNoSuchApp = errors.New("no such app")
+	// ErrNoSuchVersion is returned when asked for a version of an app which
+	// doesn't exist.
+	ErrNoSuchVersion = errors.New("no such app version")
+	// ErrInvalidName is returned for app names which can't be used in paths.
+	ErrInvalidName = errors.New("invalid app name")
+	// ErrInvalidBundle is returned when an uploaded bundle isn't a zip file, or
+	// holds files which would land outside of the app.
+	ErrInvalidBundle = errors.New("invalid app bundle")
+
+	errNoAppsDir = errors.New("apps need a directory")
+)
+
+const (
+	// currentFile in an app's directory names its current version.
+	currentFile = "CURRENT"
+	// defaultMaxUnpacked is how large a bundle may be once unpacked.
+	defaultMaxUnpacked = 1 << 30
+)
+
+// validName of an app, which must be usable as a path segment in URLs.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func checkName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// Version of an app.
+type Version struct {
+	Version  int       `json:"version"`
+	Uploaded time.Time `json:"uploaded"`
+}
+
+// App is a static web app.
+type App struct {
+	Name string `json:"name"`
+	// Current version, which is the one served.
+	Current  int       `json:"current"`
+	Versions []Version `json:"versions"`
+	// URL path at which the app is served.
+	URL string `json:"url"`
+}
+
+// Apps kept in a directory, with a subdirectory per app holding a directory
+// per version.
+type Apps struct {
+	dir         string
+	maxUnpacked int64
+
+	sync.Mutex // serializes changes to apps
+}
+
+// Open the apps in dir, creating it if necessary. Bundles larger than
+// maxUnpacked bytes once unpacked are refused; if it is zero, the limit is
+// 1GiB.
+func Open(dir string, maxUnpacked int64) (*Apps, error) {
+	if dir == "" {
+		return nil, errNoAppsDir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if maxUnpacked <= 0 {
+		maxUnpacked = defaultMaxUnpacked
+	}
+	return &Apps{dir: dir, maxUnpacked: maxUnpacked}, nil
+}
+
+// Path at which the app with name is served.
+func Path(name string) string {
+	return Prefix + name + "/"
+}
+
+func (a *Apps) appDir(name string) string {
+	return filepath.Join(a.dir, name)
+}
+
+func (a *Apps) versionDir(name string, version int) string {
+	return filepath.Join(a.appDir(name), strconv.Itoa(version))
+}
+
+// versions of the app with name, oldest first.
+func (a *Apps) versions(name string) ([]Version, error) {
+	files, err := ioutil.ReadDir(a.appDir(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %v", ErrNoSuchApp, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []Version
+	for _, f := range files {
+		v, err := strconv.Atoi(f.Name())
+		if err != nil || !f.IsDir() {
+			continue
+		}
+		versions = append(versions, Version{Version: v, Uploaded: f.ModTime()})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// current version of the app with name.
+func (a *Apps) current(name string) (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(a.appDir(name), currentFile))
+	if os.IsNotExist(err) {
+		return 0, fmt.Errorf("%w: %v", ErrNoSuchApp, name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// setCurrent version of the app with name, replacing the file atomically so
+// that requests never see it half-written.
+func (a *Apps) setCurrent(name string, version int) error {
+	tmp, err := ioutil.TempFile(a.appDir(name), ".current-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := fmt.Fprintln(tmp, version); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(a.appDir(name), currentFile))
+}
+
+// Get the app with name.
+func (a *Apps) Get(name string) (*App, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	versions, err := a.versions(name)
+	if err != nil {
+		return nil, err
+	}
+	current, err := a.current(name)
+	if err != nil {
+		return nil, err
+	}
+	return &App{
+		Name:     name,
+		Current:  current,
+		Versions: versions,
+		URL:      Path(name),
+	}, nil
+}
+
+// List the apps, by name.
+func (a *Apps) List() ([]App, error) {
+	files, err := ioutil.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	list := []App{}
+	for _, f := range files {
+		if !f.IsDir() || checkName(f.Name()) != nil {
+			continue
+		}
+		app, err := a.Get(f.Name())
+		if err != nil {
+			continue
+		}
+		list = append(list, *app)
+	}
+	return list, nil
+}
+
+// bundlePath is where the file named in a bundle is unpacked to, relative to
+// the version directory, or an error if it would land outside of it.
+func bundlePath(name string) (string, error) {
+	if strings.Contains(name, `\`) || path.IsAbs(name) {
+		return "", fmt.Errorf("%w: unsafe path %q", ErrInvalidBundle, name)
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: unsafe path %q", ErrInvalidBundle, name)
+	}
+	return filepath.FromSlash(clean), nil
+}
+
+// commonRoot of the files in a bundle, if they are all in a single directory
+// and there's no index.html outside it, as happens when zipping up the
+// directory holding an app rather than its contents.
+func commonRoot(files []*zip.File) string {
+	root := ""
+	for _, f := range files {
+		if f.Name == "index.html" {
+			return ""
+		}
+		first := strings.SplitN(f.Name, "/", 2)
+		if len(first) < 2 || (root != "" && first[0] != root) {
+			return ""
+		}
+		root = first[0]
+	}
+	if root == "" {
+		return ""
+	}
+	return root + "/"
+}
+
+// unpack the bundle into dir.
+func (a *Apps) unpack(zr *zip.Reader, dir string) error {
+	// Check every file before unpacking any, so that a bad bundle leaves
+	// nothing behind, and before stripping a common root, so that it can't hide
+	// a path out of the app.
+	for _, f := range zr.File {
+		if f.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: symlink %q", ErrInvalidBundle, f.Name)
+		}
+		if _, err := bundlePath(f.Name); err != nil {
+			return err
+		}
+	}
+	root := commonRoot(zr.File)
+	var total int64
+	for _, f := range zr.File {
+		rel, err := bundlePath(strings.TrimPrefix(f.Name, root))
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dir, rel)
+		if f.FileInfo().IsDir() || rel == "." {
+			if err := os.MkdirAll(dest, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		n, err := a.extract(f, dest, a.maxUnpacked-total)
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+	return nil
+}
+
+// extract f to dest, refusing to write more than limit bytes.
+func (a *Apps) extract(f *zip.File, dest string, limit int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if os.IsExist(err) {
+		return 0, fmt.Errorf("%w: duplicate file %q", ErrInvalidBundle, f.Name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, fmt.Errorf("%w: larger than %v bytes unpacked", ErrInvalidBundle, a.maxUnpacked)
+	}
+	return n, nil
+}
+
+// Upload a zip bundle from r as a new version of the app with name, which
+// becomes its current version.
+func (a *Apps) Upload(name string, r io.Reader) (*App, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	// Zip files are read from the end, so the bundle is spooled to disk first.
+	tmp, err := ioutil.TempFile(a.dir, ".bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	if err := os.MkdirAll(a.appDir(name), 0o755); err != nil {
+		return nil, err
+	}
+	versions, err := a.versions(name)
+	if err != nil {
+		return nil, err
+	}
+	created := false
+	if len(versions) == 0 {
+		// A failed first upload mustn't leave an app with no versions behind.
+		defer func() {
+			if !created {
+				os.RemoveAll(a.appDir(name))
+			}
+		}()
+	}
+	version := 1
+	if len(versions) > 0 {
+		version = versions[len(versions)-1].Version + 1
+	}
+	// Unpack beside the version directory, so that a failed upload never looks
+	// like a version.
+	staging, err := ioutil.TempDir(a.appDir(name), ".staging-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+	if err := a.unpack(zr, staging); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(staging, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staging, a.versionDir(name, version)); err != nil {
+		return nil, err
+	}
+	created = true
+	if err := a.setCurrent(name, version); err != nil {
+		return nil, err
+	}
+	return a.Get(name)
+}
+
+// Rollback the app with name to version, or if version is zero, to the version
+// before its current one.
+func (a *Apps) Rollback(name string, version int) (*App, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	versions, err := a.versions(name)
+	if err != nil {
+		return nil, err
+	}
+	current, err := a.current(name)
+	if err != nil {
+		return nil, err
+	}
+	target := 0
+	for _, v := range versions {
+		if (version == 0 && v.Version < current) || v.Version == version {
+			target = v.Version
+		}
+	}
+	if target == 0 {
+		if version == 0 {
+			return nil, fmt.Errorf("%w: nothing before version %v", ErrNoSuchVersion, current)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrNoSuchVersion, version)
+	}
+	if err := a.setCurrent(name, target); err != nil {
+		return nil, err
+	}
+	return a.Get(name)
+}
+
+// Delete the app with name, and all its versions.
+func (a *Apps) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	if _, err := os.Stat(a.appDir(name)); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %v", ErrNoSuchApp, name)
+	}
+	return os.RemoveAll(a.appDir(name))
+}
@@ -0,0 +1,199 @@
+package apps
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// entry in a test bundle.
+type entry struct {
+	name, body string
+	mode       os.FileMode
+}
+
+func bundle(t *testing.T, entries []entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			h.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// unpacked files under dir, as slash-separated paths.
+func unpacked(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestUpload(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		entries     []entry
+		maxUnpacked int64
+		wantErr     error
+		wantFiles   []string
+	}{
+		{
+			name:      "flat",
+			entries:   []entry{{name: "index.html", body: "hi"}, {name: "js/app.js", body: "1"}},
+			wantFiles: []string{"index.html", "js/app.js"},
+		},
+		{
+			name:      "common root stripped",
+			entries:   []entry{{name: "site/"}, {name: "site/index.html", body: "hi"}, {name: "site/js/app.js", body: "1"}},
+			wantFiles: []string{"index.html", "js/app.js"},
+		},
+		{
+			name:      "common root kept beside index.html",
+			entries:   []entry{{name: "index.html", body: "hi"}, {name: "site/app.js", body: "1"}},
+			wantFiles: []string{"index.html", "site/app.js"},
+		},
+		{
+			name:    "parent",
+			entries: []entry{{name: "../x", body: "x"}},
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:    "parent after cleaning",
+			entries: []entry{{name: "a/../../x", body: "x"}},
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:    "parent hidden by common root",
+			entries: []entry{{name: "a/index.html", body: "hi"}, {name: "a/../../x", body: "x"}},
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:    "absolute",
+			entries: []entry{{name: "/abs", body: "x"}},
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:    "backslashes",
+			entries: []entry{{name: `a\..\x`, body: "x"}},
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:    "symlink",
+			entries: []entry{{name: "index.html", body: "hi"}, {name: "link", body: "/etc/passwd", mode: os.ModeSymlink | 0o777}},
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:    "duplicate",
+			entries: []entry{{name: "index.html", body: "hi"}, {name: "index.html", body: "again"}},
+			wantErr: ErrInvalidBundle,
+		},
+		{
+			name:        "unpacked size limit",
+			entries:     []entry{{name: "index.html", body: "hi"}, {name: "big", body: strings.Repeat("x", 100)}},
+			maxUnpacked: 64,
+			wantErr:     ErrInvalidBundle,
+		},
+		{
+			name:        "within unpacked size limit",
+			entries:     []entry{{name: "index.html", body: "hi"}, {name: "big", body: strings.Repeat("x", 62)}},
+			maxUnpacked: 64,
+			wantFiles:   []string{"big", "index.html"},
+		},
+		{
+			name:    "not a zip",
+			wantErr: ErrInvalidBundle,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "apps-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			a, err := Open(dir, tc.maxUnpacked)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := bytes.NewBufferString("not a zip")
+			if tc.entries != nil {
+				body = bundle(t, tc.entries)
+			}
+			app, err := a.Upload("app", body)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("Upload() = %v, want %v", err, tc.wantErr)
+				}
+				if _, err := os.Stat(a.appDir("app")); !os.IsNotExist(err) {
+					t.Errorf("failed upload left %v behind: %v", a.appDir("app"), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Upload() = %v", err)
+			}
+			if app.Current != 1 || len(app.Versions) != 1 {
+				t.Errorf("Upload() = current %v of %v versions, want 1 of 1", app.Current, len(app.Versions))
+			}
+			got := unpacked(t, a.versionDir("app", 1))
+			if strings.Join(got, ",") != strings.Join(tc.wantFiles, ",") {
+				t.Errorf("unpacked %v, want %v", got, tc.wantFiles)
+			}
+		})
+	}
+}
+
+func TestUploadFailureKeepsVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apps-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Upload("app", bundle(t, []entry{{name: "index.html", body: "hi"}})); err != nil {
+		t.Fatalf("Upload() = %v", err)
+	}
+	if _, err := a.Upload("app", bundle(t, []entry{{name: "../x", body: "x"}})); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("Upload() = %v, want %v", err, ErrInvalidBundle)
+	}
+	app, err := a.Get("app")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if app.Current != 1 || len(app.Versions) != 1 {
+		t.Errorf("Get() = current %v of %v versions, want 1 of 1", app.Current, len(app.Versions))
+	}
+}
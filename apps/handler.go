@@ -0,0 +1,49 @@
+package apps
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Prefix under which apps are served.
+const Prefix = "/apps/"
+
+// Handler serves the current version of each app under Prefix, at
+// Prefix/{name}/.
+func (a *Apps) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, Prefix)
+		parts := strings.SplitN(rest, "/", 2)
+		name := parts[0]
+		if err := checkName(name); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if len(parts) < 2 {
+			http.Redirect(w, r, Path(name), http.StatusMovedPermanently)
+			return
+		}
+		version, err := a.current(name)
+		if err != nil {
+			if errors.Is(err, ErrNoSuchApp) {
+				http.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "couldn't open app: %v", err)
+			logrus.WithError(err).WithField("app", name).Error("opening app failed")
+			return
+		}
+		// Screens must pick up a new version or a rollback on their next load.
+		w.Header().Set("Cache-Control", "no-cache")
+		// Apps get an origin of their own, so that their scripts can't act on
+		// the API with the credentials of whoever views them.
+		w.Header().Set("Content-Security-Policy", "sandbox allow-scripts")
+		files := http.FileServer(http.Dir(a.versionDir(name, version)))
+		http.StripPrefix(strings.TrimSuffix(Path(name), "/"), files).ServeHTTP(w, r)
+	})
+}
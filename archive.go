@@ -0,0 +1,344 @@
+package pijector
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNoSuchSnapshot is returned when asked for an archived snapshot which
+	// doesn't exist.
+	ErrNoSuchSnapshot = errors.New("no such snapshot")
+
+	errNoArchiveDir = errors.New("snapshot archive needs a directory")
+)
+
+// SnapshotArchive configures an Archive.
+type SnapshotArchive struct {
+	// Dir in which snapshots are stored, in a subdirectory per Screen.
+	Dir string
+	// Interval between snapshots of each Screen. Defaults to a minute.
+	Interval time.Duration
+	// Retention of snapshots, after which they are deleted. Defaults to a week.
+	Retention time.Duration
+	// Width to which snapshots are downsized, keeping their aspect ratio.
+	// Defaults to 480 pixels.
+	Width int
+}
+
+const (
+	defaultArchiveInterval  = time.Minute
+	defaultArchiveRetention = 7 * 24 * time.Hour
+	defaultArchiveWidth     = 480
+	archiveJPEGQuality      = 75
+
+	// snapshotStamp names archived snapshots, which sort by name in time order.
+	snapshotStamp = "20060102T150405Z"
+	snapshotExt   = ".jpg"
+
+	// maxTimelapseFrames limits the size of a timelapse. Longer time ranges skip
+	// snapshots to fit.
+	maxTimelapseFrames = 600
+)
+
+// Archive keeps downsized snapshots of Screens over time.
+type Archive struct {
+	dir                 string
+	interval, retention time.Duration
+	width               int
+}
+
+// ArchivedSnapshot is a snapshot of a Screen in an Archive.
+type ArchivedSnapshot struct {
+	// ID of the snapshot, for fetching it from the Archive.
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+// OpenArchive of snapshots, creating its directory if necessary.
+func OpenArchive(cfg SnapshotArchive) (*Archive, error) {
+	if cfg.Dir == "" {
+		return nil, errNoArchiveDir
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	a := &Archive{
+		dir:       cfg.Dir,
+		interval:  cfg.Interval,
+		retention: cfg.Retention,
+		width:     cfg.Width,
+	}
+	if a.interval <= 0 {
+		a.interval = defaultArchiveInterval
+	}
+	if a.retention <= 0 {
+		a.retention = defaultArchiveRetention
+	}
+	if a.width <= 0 {
+		a.width = defaultArchiveWidth
+	}
+	return a, nil
+}
+
+// screenDir in which snapshots of the Screen with id are kept.
+func (a *Archive) screenDir(id string) string {
+	return filepath.Join(a.dir, filepath.Base(filepath.Clean("/"+id)))
+}
+
+// Watch s, archiving a snapshot of it every interval until the process exits.
+func (a *Archive) Watch(s Screen) {
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			log := logrus.WithField("screen", s.ID())
+			if err := a.capture(s, now); err != nil {
+				log.WithError(err).Warn("archiving snapshot failed")
+			}
+			if err := a.prune(s.ID(), now); err != nil {
+				log.WithError(err).Warn("pruning snapshot archive failed")
+			}
+		}
+	}()
+}
+
+// downsize img to width, keeping its aspect ratio, by averaging the pixels
+// which land on each pixel of the result.
+func downsize(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= width {
+		return img
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/height, b.Min.Y+(y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/width, b.Min.X+(x+1)*b.Dx()/width
+			var r, g, bl, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, _ := img.At(sx, sy).RGBA()
+					r, g, bl, n = r+pr>>8, g+pg>>8, bl+pb>>8, n+1
+				}
+			}
+			if n > 0 {
+				out.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), 0xff})
+			}
+		}
+	}
+	return out
+}
+
+func (a *Archive) capture(s Screen, now time.Time) error {
+	snap, err := s.Snap()
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+	img, _, err := image.Decode(snap)
+	if err != nil {
+		return err
+	}
+	dir := a.screenDir(s.ID())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that a half-written snapshot is never
+	// served.
+	tmp, err := ioutil.TempFile(dir, ".snap-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := jpeg.Encode(tmp, downsize(img, a.width), &jpeg.Options{Quality: archiveJPEGQuality}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	name := now.UTC().Format(snapshotStamp) + snapshotExt
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// prune snapshots of the Screen with id which are older than the retention.
+func (a *Archive) prune(id string, now time.Time) error {
+	snaps, err := a.History(id, time.Time{}, now.Add(-a.retention))
+	if err != nil {
+		return err
+	}
+	dir := a.screenDir(id)
+	for _, snap := range snaps {
+		if err := os.Remove(filepath.Join(dir, snap.ID+snapshotExt)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// History of snapshots of the Screen with id, taken between from and to
+// inclusive, oldest first. A zero from or to leaves that end of the range
+// open.
+func (a *Archive) History(id string, from, to time.Time) ([]ArchivedSnapshot, error) {
+	files, err := ioutil.ReadDir(a.screenDir(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []ArchivedSnapshot
+	for _, f := range files {
+		stamp := strings.TrimSuffix(f.Name(), snapshotExt)
+		if stamp == f.Name() {
+			continue
+		}
+		t, err := time.Parse(snapshotStamp, stamp)
+		if err != nil {
+			continue
+		}
+		if (!from.IsZero() && t.Before(from)) || (!to.IsZero() && t.After(to)) {
+			continue
+		}
+		snaps = append(snaps, ArchivedSnapshot{ID: stamp, Time: t})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Time.Before(snaps[j].Time) })
+	return snaps, nil
+}
+
+// Open the archived snapshot of the Screen with id, as a JPEG.
+func (a *Archive) Open(id, snapID string) (io.ReadCloser, error) {
+	if _, err := time.Parse(snapshotStamp, snapID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoSuchSnapshot, snapID)
+	}
+	f, err := os.Open(filepath.Join(a.screenDir(id), snapID+snapshotExt))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %v", ErrNoSuchSnapshot, snapID)
+	}
+	return f, err
+}
+
+// timelapseFrames picks the snapshots for a timelapse, skipping some if there
+// are too many.
+func timelapseFrames(snaps []ArchivedSnapshot) []ArchivedSnapshot {
+	if len(snaps) <= maxTimelapseFrames {
+		return snaps
+	}
+	picked := make([]ArchivedSnapshot, 0, maxTimelapseFrames)
+	for i := 0; i < maxTimelapseFrames; i++ {
+		picked = append(picked, snaps[i*len(snaps)/maxTimelapseFrames])
+	}
+	return picked
+}
+
+func (a *Archive) decode(id, snapID string) (image.Image, error) {
+	f, err := a.Open(id, snapID)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return jpeg.Decode(f)
+}
+
+// GIF timelapse of the Screen with id between from and to, showing each
+// snapshot for delay.
+func (a *Archive) GIF(w io.Writer, id string, from, to time.Time, delay time.Duration) error {
+	snaps, err := a.History(id, from, to)
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		return fmt.Errorf("%w: none between %v and %v", ErrNoSuchSnapshot, from, to)
+	}
+	anim := &gif.GIF{}
+	// Every frame must fit the first, so those of a screen whose resolution
+	// changed are cropped or padded to it.
+	var bounds image.Rectangle
+	for _, snap := range timelapseFrames(snaps) {
+		img, err := a.decode(id, snap.ID)
+		if err != nil {
+			logrus.WithError(err).WithField("snapshot", snap.ID).Warn("skipping unreadable snapshot")
+			continue
+		}
+		if len(anim.Image) == 0 {
+			bounds = image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+		}
+		frame := image.NewPaletted(bounds, palette.Plan9)
+		draw.FloydSteinberg.Draw(frame, frame.Rect, img, img.Bounds().Min)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, int(delay/(10*time.Millisecond)))
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// MJPEG timelapse of the Screen with id between from and to, written as parts
+// of mw, showing each snapshot for delay. flush, if set, is called after each
+// snapshot is written. Writing stops early if done is closed.
+func (a *Archive) MJPEG(mw *multipart.Writer, flush func(), id string, from, to time.Time, delay time.Duration, done <-chan struct{}) error {
+	snaps, err := a.History(id, from, to)
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		return fmt.Errorf("%w: none between %v and %v", ErrNoSuchSnapshot, from, to)
+	}
+	for i, snap := range timelapseFrames(snaps) {
+		if i > 0 {
+			select {
+			case <-done:
+				return nil
+			case <-time.After(delay):
+			}
+		}
+		data, err := a.read(id, snap.ID)
+		if err != nil {
+			logrus.WithError(err).WithField("snapshot", snap.ID).Warn("skipping unreadable snapshot")
+			continue
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":   {"image/jpeg"},
+			"Content-Length": {fmt.Sprint(len(data))},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(data); err != nil {
+			return err
+		}
+		if flush != nil {
+			flush()
+		}
+	}
+	return nil
+}
+
+func (a *Archive) read(id, snapID string) ([]byte, error) {
+	f, err := a.Open(id, snapID)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
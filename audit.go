@@ -0,0 +1,281 @@
+package pijector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var errNoAuditPath = errors.New("audit log needs a path")
+
+const (
+	defaultAuditMaxSize = 10 << 20
+	defaultAuditKeep    = 5
+)
+
+// AuditEntry records a control action taken through the API.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Client address the action came from, and the User it gave with the admin
+	// password, if the action needed it.
+	Client string `json:"client"`
+	User   string `json:"user,omitempty"`
+	// Action taken, like "show" or "input".
+	Action string `json:"action"`
+	Screen string `json:"screen,omitempty"`
+	// Target URL of the action, if it had one.
+	Target string `json:"target,omitempty"`
+	// Params of the request, other than the target.
+	Params map[string]string `json:"params,omitempty"`
+	// Status of the API response, and Result summarizing it as "ok", "denied"
+	// or "failed".
+	Status int    `json:"status"`
+	Result string `json:"result"`
+}
+
+// AuditResult summarizes an HTTP status for an AuditEntry.
+func AuditResult(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return "ok"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "denied"
+	default:
+		return "failed"
+	}
+}
+
+// AuditLogConfig configures an AuditLog.
+type AuditLogConfig struct {
+	// Path of the file to which entries are appended, as JSON lines.
+	Path string
+	// MaxSize in bytes the file reaches before it is rotated. Defaults to 10MiB.
+	MaxSize int64
+	// Keep this many rotated files, named Path.1 (the newest) to Path.Keep.
+	// Defaults to 5.
+	Keep int
+}
+
+// AuditLog is a record of control actions, kept in a rotating JSON lines file.
+type AuditLog struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	sync.Mutex // protects following members
+	f          *os.File
+	size       int64
+}
+
+// OpenAuditLog appending to the file at its path.
+func OpenAuditLog(cfg AuditLogConfig) (*AuditLog, error) {
+	if cfg.Path == "" {
+		return nil, errNoAuditPath
+	}
+	a := &AuditLog{
+		path:    cfg.Path,
+		maxSize: cfg.MaxSize,
+		keep:    cfg.Keep,
+	}
+	if a.maxSize <= 0 {
+		a.maxSize = defaultAuditMaxSize
+	}
+	if a.keep <= 0 {
+		a.keep = defaultAuditKeep
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AuditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.f, a.size = f, info.Size()
+	return nil
+}
+
+// rotated is the name of the nth rotated file, counting from 1.
+func (a *AuditLog) rotated(n int) string {
+	return fmt.Sprintf("%v.%d", a.path, n)
+}
+
+// rotateLocked the file, dropping the oldest rotated file if there are already
+// enough. The file is reopened even if rotating it fails, so that entries keep
+// being recorded. This function assumes the lock is held before calling.
+func (a *AuditLog) rotateLocked() error {
+	a.f.Close()
+	a.f = nil
+	var err error
+	for n := a.keep - 1; n > 0 && err == nil; n-- {
+		if err = os.Rename(a.rotated(n), a.rotated(n+1)); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = os.Rename(a.path, a.rotated(1))
+	}
+	if openErr := a.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// Record e in the log.
+func (a *AuditLog) Record(e AuditEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(&e)
+	if err != nil {
+		logrus.WithError(err).Error("recording audit entry failed")
+		return
+	}
+	data = append(data, '\n')
+	a.Lock()
+	defer a.Unlock()
+	log := logrus.WithField("path", a.path)
+	if a.f != nil && a.size > 0 && a.size+int64(len(data)) > a.maxSize {
+		if err := a.rotateLocked(); err != nil {
+			log.WithError(err).Error("rotating audit log failed")
+		}
+	}
+	if a.f == nil {
+		if err := a.open(); err != nil {
+			log.WithError(err).Error("recording audit entry failed")
+			return
+		}
+	}
+	n, err := a.f.Write(data)
+	a.size += int64(n)
+	if err != nil {
+		log.WithError(err).Error("recording audit entry failed")
+	}
+}
+
+// AuditFilter selects entries from an AuditLog. Empty fields match anything.
+type AuditFilter struct {
+	Screen, Action, Client, User, Result string
+	From, To                             time.Time
+	// Limit on the number of entries returned, keeping the most recent.
+	Limit int
+}
+
+func (f *AuditFilter) match(e *AuditEntry) bool {
+	return (f.Screen == "" || f.Screen == e.Screen) &&
+		(f.Action == "" || f.Action == e.Action) &&
+		(f.Client == "" || f.Client == e.Client) &&
+		(f.User == "" || f.User == e.User) &&
+		(f.Result == "" || f.Result == e.Result) &&
+		(f.From.IsZero() || !e.Time.Before(f.From)) &&
+		(f.To.IsZero() || !e.Time.After(f.To))
+}
+
+// Query the log, including rotated files, for entries matching f, most recent
+// first.
+func (a *AuditLog) Query(f AuditFilter) ([]AuditEntry, error) {
+	// Open the files while holding the lock, so that they can't be rotated in
+	// between, then read them without holding up actions being recorded.
+	// Rotating only renames files, so those already open still read the same.
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	a.Lock()
+	// Oldest first, so that entries end up in the order they were recorded.
+	for n := a.keep; n >= 0; n-- {
+		path := a.path
+		if n > 0 {
+			path = a.rotated(n)
+		}
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			a.Unlock()
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	a.Unlock()
+	var entries []AuditEntry
+	for _, file := range files {
+		if err := scanAudit(file, &f, &entries); err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[:f.Limit]
+	}
+	return entries, nil
+}
+
+// maxAuditLine is the longest entry read back from the log. Longer ones are
+// skipped, rather than ending the scan.
+const maxAuditLine = 1 << 20
+
+// readLine from rd, without its newline. tooLong is set, and line empty, if the
+// line was longer than max.
+func readLine(rd *bufio.Reader, max int) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := rd.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > max {
+				line, tooLong = nil, true
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err != bufio.ErrBufferFull {
+			return bytes.TrimSuffix(line, []byte("\n")), tooLong, err
+		}
+	}
+}
+
+// scanAudit file for entries matching f, appending them to entries.
+func scanAudit(file *os.File, f *AuditFilter, entries *[]AuditEntry) error {
+	log := logrus.WithField("path", file.Name())
+	rd := bufio.NewReader(file)
+	for {
+		line, tooLong, err := readLine(rd, maxAuditLine)
+		if tooLong {
+			log.Warn("skipping oversized audit entry")
+		} else if len(line) > 0 {
+			var e AuditEntry
+			if err := json.Unmarshal(line, &e); err != nil {
+				log.WithError(err).Warn("skipping unreadable audit entry")
+			} else if f.match(&e) {
+				*entries = append(*entries, e)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
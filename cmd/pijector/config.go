@@ -2,9 +2,15 @@ package main
 
 import (
 	"io"
+	"net"
+	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/cfunkhouser/pijector"
+	"github.com/cfunkhouser/pijector/apps"
+	"github.com/cfunkhouser/pijector/layout"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
@@ -12,24 +18,205 @@ import (
 type screenConfig struct {
 	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
 	Address string `json:"address" yaml:"address"`
-	// TODO(cfunkhouser): Add password for remote screens.
+	// DefaultURL overrides the server's default_url for this screen.
+	DefaultURL string `json:"default_url,omitempty" yaml:"default_url,omitempty"`
+	// Idle resets an interactive screen to its home URL after nobody has
+	// touched it for a while.
+	Idle *idleConfig `json:"idle,omitempty" yaml:"idle,omitempty"`
+	// Lockdown restricts the URLs the screen may display.
+	Lockdown *lockdownConfig `json:"lockdown,omitempty" yaml:"lockdown,omitempty"`
+	// Popups is what to do with windows opened by the screen's page, either
+	// "close" (the default) or "takeover".
+	Popups string `json:"popups,omitempty" yaml:"popups,omitempty"`
+	// Inject CSS and JavaScript into matching pages on this screen, in addition
+	// to the server's rules.
+	Inject []pijector.InjectionRule `json:"inject,omitempty" yaml:"inject,omitempty"`
+	// Emulation of viewport, zoom, rotation and user agent on this screen.
+	Emulation pijector.Emulation `json:"emulation,omitempty" yaml:"emulation,omitempty"`
+	// Retry pages which fail to load on this screen, and fall back to another
+	// URL if they keep failing.
+	Retry *retryConfig `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Monitor the screen's content, flagging it when it goes stale or blank.
+	Monitor *monitorConfig `json:"monitor,omitempty" yaml:"monitor,omitempty"`
+	// Password for a remote screen's server, needed for its admin-only
+	// endpoints.
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+type idleConfig struct {
+	// Timeout after the last interaction before the screen is reset.
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// HomeURL to which the screen is reset. Defaults to the screen's default URL.
+	HomeURL string `json:"home_url,omitempty" yaml:"home_url,omitempty"`
+	// Countdown during which visitors are warned before the reset happens.
+	Countdown time.Duration `json:"countdown,omitempty" yaml:"countdown,omitempty"`
+}
+
+type retryConfig struct {
+	// Retries of a failed page before falling back.
+	Retries int `json:"retries" yaml:"retries"`
+	// Backoff before the first retry, doubling for each one after it.
+	Backoff time.Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	// FallbackURL shown when every retry has failed.
+	FallbackURL string `json:"fallback_url,omitempty" yaml:"fallback_url,omitempty"`
+}
+
+type monitorConfig struct {
+	// Interval between screenshots.
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// StaleAfter is how long a page expected to change may look the same.
+	StaleAfter time.Duration `json:"stale_after,omitempty" yaml:"stale_after,omitempty"`
+	// ExpectChange patterns match URLs of pages which should keep changing.
+	ExpectChange []string `json:"expect_change,omitempty" yaml:"expect_change,omitempty"`
+	// Reload the page when the screen goes stale or blank.
+	Reload bool `json:"reload,omitempty" yaml:"reload,omitempty"`
+}
+
+type lockdownConfig struct {
+	Allow      []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny       []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+	BlockedURL string   `json:"blocked_url,omitempty" yaml:"blocked_url,omitempty"`
 }
 
 func naivelyIsRemote(addr string) bool {
 	return strings.Contains(addr, "/api/v1/screen/")
 }
 
-func (c *screenConfig) attach() (pijector.Screen, error) {
+// defaultURL for the screen, falling back to the server-wide default.
+func (c *screenConfig) defaultURL(fallback string) string {
+	if c.DefaultURL != "" {
+		return c.DefaultURL
+	}
+	return fallback
+}
+
+func (c *screenConfig) attach(server *serverConfig, plays *pijector.PlayLog) (pijector.Screen, error) {
 	if naivelyIsRemote(c.Address) {
-		return pijector.AttachRemote(c.Name, c.Address)
+		var opts []pijector.RemoteOption
+		if c.Password != "" {
+			opts = append(opts, pijector.WithPassword(c.Password))
+		}
+		return pijector.AttachRemote(c.Name, c.Address, opts...)
 	}
-	return pijector.AttachLocal(c.Name, c.Address)
+	popups, err := pijector.ParsePopupPolicy(c.Popups)
+	if err != nil {
+		return nil, err
+	}
+	opts := []pijector.LocalOption{
+		pijector.WithDefaultURL(c.defaultURL(server.DefaultURL)),
+		pijector.WithPopupPolicy(popups),
+		pijector.WithInjectionRules(server.Inject...),
+		pijector.WithInjectionRules(c.Inject...),
+		pijector.WithEmulation(c.Emulation),
+		pijector.WithPlayLog(plays),
+	}
+	if c.Idle != nil {
+		opts = append(opts, pijector.WithIdleReset(c.Idle.HomeURL, c.Idle.Timeout, c.Idle.Countdown))
+	}
+	if c.Retry != nil {
+		opts = append(opts, pijector.WithLoadRetry(pijector.LoadRetry{
+			Retries:     c.Retry.Retries,
+			Backoff:     c.Retry.Backoff,
+			FallbackURL: c.Retry.FallbackURL,
+		}))
+	}
+	if c.Monitor != nil {
+		opts = append(opts, pijector.WithContentMonitor(pijector.ContentMonitor{
+			Interval:     c.Monitor.Interval,
+			StaleAfter:   c.Monitor.StaleAfter,
+			ExpectChange: c.Monitor.ExpectChange,
+			Reload:       c.Monitor.Reload,
+		}))
+	}
+	if c.Lockdown != nil {
+		opts = append(opts, pijector.WithNavigationPolicy(pijector.NavigationPolicy{
+			Allow:      c.Lockdown.Allow,
+			Deny:       c.Lockdown.Deny,
+			BlockedURL: c.Lockdown.BlockedURL,
+			Framing:    server.framingPages(),
+		}))
+	}
+	return pijector.AttachLocal(c.Name, c.Address, opts...)
 }
 
 type serverConfig struct {
 	Listen     string         `json:"listen" yaml:"listen"`
 	DefaultURL string         `json:"default_url" yaml:"default_url"`
 	Screens    []screenConfig `json:"screens" yaml:"screens"`
+	// Inject CSS and JavaScript into matching pages on every local screen.
+	// Rules can only be changed through the API when AdminPassword is set.
+	Inject []pijector.InjectionRule `json:"inject,omitempty" yaml:"inject,omitempty"`
+	// AdminPassword enables admin-only API endpoints, such as eval and adding
+	// or removing injection rules, for clients which send it.
+	AdminPassword string `json:"admin_password,omitempty" yaml:"admin_password,omitempty"`
+	// Archive snapshots of every screen, for history and timelapses.
+	Archive *archiveConfig `json:"archive,omitempty" yaml:"archive,omitempty"`
+	// ProofOfPlay records what every local screen displays, for reporting.
+	ProofOfPlay *proofOfPlayConfig `json:"proof_of_play,omitempty" yaml:"proof_of_play,omitempty"`
+	// Audit records every control action taken through the API.
+	Audit *auditConfig `json:"audit,omitempty" yaml:"audit,omitempty"`
+	// PublicURL at which screens reach this server, for showing hosted content
+	// on remote screens. Defaults to the address the API was called at.
+	PublicURL string `json:"public_url,omitempty" yaml:"public_url,omitempty"`
+	// MaxUpload is the largest file in bytes which may be uploaded.
+	MaxUpload int64 `json:"max_upload,omitempty" yaml:"max_upload,omitempty"`
+	// Content is a library of media uploaded for screens to show.
+	Content *contentConfig `json:"content,omitempty" yaml:"content,omitempty"`
+	// Apps are static web apps uploaded for screens to show.
+	Apps *appsConfig `json:"apps,omitempty" yaml:"apps,omitempty"`
+	// Layouts split screens into regions, each showing its own URLs.
+	Layouts *layoutsConfig `json:"layouts,omitempty" yaml:"layouts,omitempty"`
+	// Readiness decides when /readyz reports the server as ready.
+	Readiness readinessConfig `json:"readiness,omitempty" yaml:"readiness,omitempty"`
+}
+
+type auditConfig struct {
+	// Path of the file to which entries are appended, as JSON lines.
+	Path string `json:"path" yaml:"path"`
+	// MaxSize in bytes of the file before it is rotated.
+	MaxSize int64 `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+	// Keep this many rotated files.
+	Keep int `json:"keep,omitempty" yaml:"keep,omitempty"`
+}
+
+type contentConfig struct {
+	// Dir in which uploaded media is stored.
+	Dir string `json:"dir" yaml:"dir"`
+}
+
+type appsConfig struct {
+	// Dir in which apps are unpacked.
+	Dir string `json:"dir" yaml:"dir"`
+	// MaxUnpacked is the largest an app bundle may be in bytes once unpacked.
+	MaxUnpacked int64 `json:"max_unpacked,omitempty" yaml:"max_unpacked,omitempty"`
+}
+
+type layoutsConfig struct {
+	// Dir in which layouts are saved.
+	Dir string `json:"dir" yaml:"dir"`
+}
+
+type readinessConfig struct {
+	// MinUp is how many screens must be up. Defaults to all of them.
+	MinUp int `json:"min_up,omitempty" yaml:"min_up,omitempty"`
+	// Timeout for checking each screen.
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+type proofOfPlayConfig struct {
+	// Path of the file to which plays are appended, as JSON lines.
+	Path string `json:"path" yaml:"path"`
+}
+
+type archiveConfig struct {
+	// Dir in which snapshots are stored.
+	Dir string `json:"dir" yaml:"dir"`
+	// Interval between snapshots of each screen.
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// Retention of snapshots, after which they are deleted.
+	Retention time.Duration `json:"retention,omitempty" yaml:"retention,omitempty"`
+	// Width to which snapshots are downsized.
+	Width int `json:"width,omitempty" yaml:"width,omitempty"`
 }
 
 var (
@@ -43,11 +230,69 @@ var (
 	}
 )
 
+// baseURLs at which screens may reach this server, as hosted content URLs are
+// built: its public_url, and its listen address, under any of this machine's
+// names when it listens on all of them.
+func (c *serverConfig) baseURLs() []string {
+	var bases []string
+	if u, err := url.Parse(c.PublicURL); err == nil && u.Host != "" {
+		bases = append(bases, strings.TrimSuffix(c.PublicURL, "/"))
+	}
+	host, port, err := net.SplitHostPort(c.Listen)
+	if err != nil {
+		return bases
+	}
+	hosts := []string{host}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		hosts = []string{"localhost"}
+		if name, err := os.Hostname(); err == nil {
+			hosts = append(hosts, name)
+		}
+		addrs, _ := net.InterfaceAddrs()
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok {
+				hosts = append(hosts, ipn.IP.String())
+			}
+		}
+	}
+	for _, h := range hosts {
+		bases = append(bases, "http://"+net.JoinHostPort(h, port))
+	}
+	return bases
+}
+
+// framingPages served by this server, which frame other pages on a screen's
+// behalf: its layouts and apps.
+func (c *serverConfig) framingPages() []string {
+	var pages []string
+	for _, base := range c.baseURLs() {
+		pages = append(pages, base+layout.Prefix, base+apps.Prefix)
+	}
+	return pages
+}
+
+// redactedSecret stands in for passwords in logged configs.
+const redactedSecret = "REDACTED"
+
+// redacted copy of the config, with its passwords masked, for logging.
+func (c serverConfig) redacted() serverConfig {
+	if c.AdminPassword != "" {
+		c.AdminPassword = redactedSecret
+	}
+	c.Screens = append([]screenConfig(nil), c.Screens...)
+	for i := range c.Screens {
+		if c.Screens[i].Password != "" {
+			c.Screens[i].Password = redactedSecret
+		}
+	}
+	return c
+}
+
 func Load(r io.Reader) (*serverConfig, error) {
 	config := defaultServerConfig
 	if err := yaml.NewDecoder(r).Decode(&config); err != nil {
 		return nil, err
 	}
-	logrus.Debugf("Loaded config: %+v", config)
+	logrus.Debugf("Loaded config: %+v", config.redacted())
 	return &config, nil
 }
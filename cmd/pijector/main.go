@@ -5,11 +5,18 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/cfunkhouser/pijector"
 	"github.com/cfunkhouser/pijector/admin"
 	"github.com/cfunkhouser/pijector/api"
+	"github.com/cfunkhouser/pijector/apps"
+	"github.com/cfunkhouser/pijector/content"
+	"github.com/cfunkhouser/pijector/layout"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
@@ -60,20 +67,102 @@ func serve(c *cli.Context) error {
 		return cli.Exit(err, 1)
 	}
 
+	apiOpts := []api.Option{
+		api.WithAdminPassword(cfg.AdminPassword),
+		api.WithPublicURL(cfg.PublicURL),
+		api.WithMaxUpload(cfg.MaxUpload),
+	}
+	var plays *pijector.PlayLog
+	if cfg.ProofOfPlay != nil {
+		if plays, err = pijector.OpenPlayLog(cfg.ProofOfPlay.Path); err != nil {
+			return cli.Exit(err, 1)
+		}
+		apiOpts = append(apiOpts, api.WithPlayLog(plays))
+	}
+
+	if cfg.Audit != nil {
+		audit, err := pijector.OpenAuditLog(pijector.AuditLogConfig{
+			Path:    cfg.Audit.Path,
+			MaxSize: cfg.Audit.MaxSize,
+			Keep:    cfg.Audit.Keep,
+		})
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+		apiOpts = append(apiOpts, api.WithAuditLog(audit))
+	}
+
+	var lib *content.Library
+	if cfg.Content != nil {
+		if lib, err = content.OpenLibrary(cfg.Content.Dir); err != nil {
+			return cli.Exit(err, 1)
+		}
+		apiOpts = append(apiOpts, api.WithMediaLibrary(lib))
+	}
+	var hosted *apps.Apps
+	if cfg.Apps != nil {
+		if hosted, err = apps.Open(cfg.Apps.Dir, cfg.Apps.MaxUnpacked); err != nil {
+			return cli.Exit(err, 1)
+		}
+		apiOpts = append(apiOpts, api.WithApps(hosted))
+	}
+	var layouts *layout.Layouts
+	if cfg.Layouts != nil {
+		if layouts, err = layout.Open(cfg.Layouts.Dir); err != nil {
+			return cli.Exit(err, 1)
+		}
+		apiOpts = append(apiOpts, api.WithLayouts(layouts))
+	}
+
 	var screens []pijector.Screen
+	defaults := make(map[string]string)
 	for _, scfg := range cfg.Screens {
-		s, err := scfg.attach()
+		s, err := scfg.attach(cfg, plays)
 		if err != nil {
 			logrus.WithError(err).WithField("address", scfg.Address).Warn("attach failed")
 			// return cli.Exit(err, 1)
-		} else {
-			logrus.WithField("address", scfg.Address).Info("attached to screen")
+			continue
 		}
+		logrus.WithField("address", scfg.Address).Info("attached to screen")
 		screens = append(screens, s)
+		defaults[s.ID()] = scfg.defaultURL(cfg.DefaultURL)
+	}
+
+	if cfg.Archive != nil {
+		archive, err := pijector.OpenArchive(pijector.SnapshotArchive{
+			Dir:       cfg.Archive.Dir,
+			Interval:  cfg.Archive.Interval,
+			Retention: cfg.Archive.Retention,
+			Width:     cfg.Archive.Width,
+		})
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+		for _, s := range screens {
+			archive.Watch(s)
+		}
+		apiOpts = append(apiOpts, api.WithArchive(archive))
 	}
 
+	prometheus.MustRegister(pijector.NewScreenCollector(screens))
+
 	r := mux.NewRouter()
-	api.HandleV1(r, screens)
+	api.HandleV1(r, screens, apiOpts...)
+	api.HandleHealth(r, screens, api.ReadinessPolicy{
+		MinUp:   cfg.Readiness.MinUp,
+		Timeout: cfg.Readiness.Timeout,
+	})
+	r.Methods(http.MethodGet).Path("/metrics").Handler(promhttp.Handler())
+	if lib != nil {
+		r.PathPrefix(content.Prefix).Handler(lib.Handler())
+	}
+	if hosted != nil {
+		r.PathPrefix(apps.Prefix).Handler(hosted.Handler())
+	}
+	if layouts != nil {
+		r.PathPrefix(layout.Prefix).Handler(layouts.Handler())
+	}
+	r.Methods(http.MethodGet).Path(admin.MessagePath).HandlerFunc(admin.MessageHandler)
 	r.PathPrefix("/").HandlerFunc(admin.Handler)
 	http.Handle("/", r)
 
@@ -84,16 +173,28 @@ func serve(c *cli.Context) error {
 	}(done, cfg.Listen)
 
 	for _, s := range screens {
-		if err := s.Show(cfg.DefaultURL); err != nil {
+		if err := s.Show(defaults[s.ID()]); err != nil {
 			logrus.WithError(err).WithFields(logrus.Fields{
-				"target": cfg.DefaultURL,
+				"target": defaults[s.ID()],
 				"screen": s.ID(),
 			}).Warning("show failed")
 		}
 	}
 
-	err = <-done
-	logrus.WithError(err).Infof("server done listening")
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-done:
+		logrus.WithError(err).Infof("server done listening")
+	case sig := <-stop:
+		logrus.WithField("signal", sig).Info("shutting down")
+	}
+	if plays != nil {
+		// Record the end of whatever the screens are showing.
+		if err := plays.Close(); err != nil {
+			logrus.WithError(err).Error("closing proof of play log failed")
+		}
+	}
 	return err
 }
 
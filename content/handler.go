@@ -0,0 +1,163 @@
+package content
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Prefix under which the Library is served.
+const Prefix = "/content/"
+
+const (
+	filesDir = "files/"
+	viewDir  = "view/"
+)
+
+// FilePath at which the media item with name is served.
+func FilePath(name string) string {
+	return Prefix + filesDir + url.PathEscape(name)
+}
+
+// ViewPath at which the full-screen viewer for the media item with name is
+// served.
+func ViewPath(name string) string {
+	return Prefix + viewDir + url.PathEscape(name)
+}
+
+type viewerData struct {
+	Item *Item
+	// Fit is the CSS object-fit for images and videos, "contain" or "cover".
+	Fit string
+}
+
+var viewer = template.Must(template.New("viewer").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Item.Name}}</title>
+<style>
+html, body { margin: 0; height: 100%; overflow: hidden; background: black; }
+img, video, iframe { display: block; width: 100vw; height: 100vh; border: 0; object-fit: {{.Fit}}; }
+iframe { pointer-events: none; }
+</style>
+</head>
+<body>
+{{- if eq .Item.Kind "image"}}
+<img src="{{.Item.FileURL}}" alt="{{.Item.Name}}">
+{{- else if eq .Item.Kind "video"}}
+<video src="{{.Item.FileURL}}" autoplay muted loop playsinline></video>
+{{- else}}
+<script>
+((window) => {
+    const file = {{.Item.FileURL}}, total = {{.Item.Pages}};
+    let page = parseInt(new URLSearchParams(location.search).get('page'), 10) || 1;
+    const show = (n) => {
+        if (n < 1 || (total && n > total)) {
+            return;
+        }
+        page = n;
+        // The PDF viewer only reliably honours #page when the frame loads, so
+        // each page gets a fresh frame.
+        const frame = document.createElement('iframe');
+        frame.tabIndex = -1;
+        frame.src = file + '#page=' + page + '&toolbar=0&navpanes=0&view=Fit';
+        document.querySelectorAll('iframe').forEach((f) => f.remove());
+        document.body.appendChild(frame);
+        window.focus();
+    };
+    window.pijectorSlide = () => ({slide: page, total: total || null});
+    window.addEventListener('keydown', (e) => {
+        switch (e.key) {
+            case 'ArrowRight': case 'ArrowDown': case 'PageDown': case ' ':
+                show(page + 1); break;
+            case 'ArrowLeft': case 'ArrowUp': case 'PageUp':
+                show(page - 1); break;
+            case 'Home':
+                show(1); break;
+            case 'End':
+                if (total) { show(total); } break;
+            default:
+                return;
+        }
+        e.preventDefault();
+    });
+    window.addEventListener('load', () => show(page));
+})(window);
+</script>
+{{- end}}
+</body>
+</html>
+`))
+
+// Handler serves the Library under Prefix: each media file under "files/", and
+// a full-screen viewer page for it under "view/". Viewers for images and
+// videos take a "fit" parameter, "contain" (the default) to fit the whole of
+// it on the screen, or "cover" to fill the screen. PDF viewers page through the
+// document with the arrow keys, and take a "page" parameter to start from.
+func (l *Library) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, Prefix)
+		switch {
+		case strings.HasPrefix(rest, filesDir):
+			l.serveFile(w, r, strings.TrimPrefix(rest, filesDir))
+		case strings.HasPrefix(rest, viewDir):
+			l.serveViewer(w, r, strings.TrimPrefix(rest, viewDir))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// notFound tells the client about err, as a 404 if it means there's no such
+// media.
+func notFound(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrNoSuchMedia) || errors.Is(err, ErrInvalidName) || errors.Is(err, ErrUnsupportedMedia) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "couldn't open media: %v", err)
+	logrus.WithError(err).WithField("client", r.RemoteAddr).Error("opening media failed")
+}
+
+func (l *Library) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	it, err := l.Get(name)
+	if err != nil {
+		notFound(w, r, err)
+		return
+	}
+	f, err := os.Open(filepath.Join(l.dir, it.Name))
+	if err != nil {
+		notFound(w, r, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", it.ContentType)
+	// ServeContent handles range requests, which video playback relies on.
+	http.ServeContent(w, r, it.Name, it.Modified, f)
+}
+
+func (l *Library) serveViewer(w http.ResponseWriter, r *http.Request, name string) {
+	it, err := l.Get(name)
+	if err != nil {
+		notFound(w, r, err)
+		return
+	}
+	data := &viewerData{Item: it, Fit: "contain"}
+	if r.URL.Query().Get("fit") == "cover" {
+		data.Fit = "cover"
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := viewer.Execute(w, data); err != nil {
+		logrus.WithError(err).WithField("client", r.RemoteAddr).Error("returning media viewer failed")
+	}
+}
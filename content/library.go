@@ -0,0 +1,301 @@
+// Package content hosts a library of media files for pijector screens to
+// display, with a full-screen viewer page for each.
+package content
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoSuchMedia is returned when asked for a media item which isn't in the
+	// Library.
+	ErrNoSuchMedia = errors.New("no such media")
+	// ErrUnsupportedMedia is returned when asked to store a file which isn't an
+	// image, video or PDF.
+	ErrUnsupportedMedia = errors.New("unsupported media type")
+	// ErrInvalidName is returned for media names which can't be used as file
+	// names.
+	ErrInvalidName = errors.New("invalid media name")
+
+	errNoLibraryDir = errors.New("media library needs a directory")
+)
+
+// Kind of media, which decides how it is viewed.
+type Kind string
+
+const (
+	Image Kind = "image"
+	Video Kind = "video"
+	PDF   Kind = "pdf"
+)
+
+// sniffLen is how much of a file is read to work out its type.
+const sniffLen = 512
+
+// pagesExt of the hidden file beside each PDF which holds its page count.
+const pagesExt = ".pages"
+
+// kindOf the content type, if it is one the Library can view.
+func kindOf(contentType string) (Kind, bool) {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return Image, true
+	case strings.HasPrefix(contentType, "video/"):
+		return Video, true
+	case contentType == "application/pdf":
+		return PDF, true
+	}
+	return "", false
+}
+
+// Item is a media file in the Library.
+type Item struct {
+	Name        string    `json:"name"`
+	Kind        Kind      `json:"kind"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	// FileURL and ViewURL are the paths at which the file and its full-screen
+	// viewer are served.
+	FileURL string `json:"file_url"`
+	ViewURL string `json:"view_url"`
+	// Pages in a PDF, or 0 if they couldn't be counted.
+	Pages int `json:"pages,omitempty"`
+}
+
+// Library of media files, kept in a directory.
+type Library struct {
+	dir string
+}
+
+// OpenLibrary in dir, creating it if necessary.
+func OpenLibrary(dir string) (*Library, error) {
+	if dir == "" {
+		return nil, errNoLibraryDir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Library{dir: dir}, nil
+}
+
+// checkName is safe to use as a file name in the Library.
+func checkName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) || name != filepath.Base(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// sniff the content type of the file at path.
+func sniff(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// pdfPage matches each page object in a PDF. Pages in compressed object
+// streams aren't found, in which case the page count is unknown.
+var pdfPage = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// pdfOverlap is how much of each chunk of a PDF is carried over to the next
+// while counting pages, so that page objects split between chunks are found.
+const pdfOverlap = 256
+
+// countPages in the PDF read from r, a chunk at a time.
+func countPages(r io.Reader) (int, error) {
+	var pages int
+	buf := make([]byte, 0, 64<<10)
+	for {
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		eof := err == io.EOF
+		if err != nil && !eof {
+			return 0, err
+		}
+		// Page objects starting within the overlap are left for the next chunk.
+		limit := len(buf)
+		if !eof {
+			if limit < cap(buf) {
+				continue
+			}
+			limit -= pdfOverlap
+		}
+		for _, m := range pdfPage.FindAllIndex(buf, -1) {
+			if m[0] < limit {
+				pages++
+			}
+		}
+		if eof {
+			return pages, nil
+		}
+		buf = buf[:copy(buf, buf[limit:])]
+	}
+}
+
+// pdfPagesOf the PDF at path.
+func pdfPagesOf(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return countPages(f)
+}
+
+// pagesFile holding the page count of the PDF with name.
+func (l *Library) pagesFile(name string) string {
+	return filepath.Join(l.dir, "."+name+pagesExt)
+}
+
+// item describing the file with name, or an error if it isn't viewable media.
+func (l *Library) item(name string, info os.FileInfo) (*Item, error) {
+	ct, err := sniff(filepath.Join(l.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	kind, ok := kindOf(ct)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedMedia, ct)
+	}
+	it := &Item{
+		Name:        name,
+		Kind:        kind,
+		ContentType: ct,
+		Size:        info.Size(),
+		Modified:    info.ModTime(),
+		FileURL:     FilePath(name),
+		ViewURL:     ViewPath(name),
+	}
+	if kind == PDF {
+		if data, err := ioutil.ReadFile(l.pagesFile(name)); err == nil {
+			it.Pages, _ = strconv.Atoi(string(data))
+		}
+	}
+	return it, nil
+}
+
+// List the media in the Library, by name.
+func (l *Library) List() ([]Item, error) {
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	items := []Item{}
+	for _, f := range files {
+		if f.IsDir() || checkName(f.Name()) != nil {
+			continue
+		}
+		it, err := l.item(f.Name(), f)
+		if err != nil {
+			continue
+		}
+		items = append(items, *it)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+// Get the media item with name.
+func (l *Library) Get(name string) (*Item, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filepath.Join(l.dir, name))
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, fmt.Errorf("%w: %v", ErrNoSuchMedia, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.item(name, info)
+}
+
+// Save the media read from r as name, replacing any media with that name. It
+// is refused unless it is an image, video or PDF.
+func (l *Library) Save(name string, r io.Reader) (*Item, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	// Write to a temporary file first, so that a half-uploaded file is never
+	// served.
+	tmp, err := ioutil.TempFile(l.dir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	ct, err := sniff(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	kind, ok := kindOf(ct)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedMedia, ct)
+	}
+	// Count the pages of a PDF now, rather than every time it's viewed.
+	var pages int
+	if kind == PDF {
+		if pages, err = pdfPagesOf(tmp.Name()); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(l.dir, name)); err != nil {
+		return nil, err
+	}
+	if pages > 0 {
+		err = ioutil.WriteFile(l.pagesFile(name), []byte(strconv.Itoa(pages)), 0o644)
+	} else {
+		err = os.Remove(l.pagesFile(name))
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return l.Get(name)
+}
+
+// Delete the media item with name.
+func (l *Library) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(l.dir, name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %v", ErrNoSuchMedia, name)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(l.pagesFile(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package pijector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidEmulation is returned when asked for an Emulation which can't be done.
+var ErrInvalidEmulation = errors.New("invalid emulation")
+
+// Emulation overrides how a Screen presents itself to the pages it displays.
+// The zero value emulates nothing, leaving Chromium to its native behavior.
+type Emulation struct {
+	// Width and Height of the viewport in pixels. If only one is set, the other
+	// is taken from the browser window.
+	Width  int `json:"width,omitempty" yaml:"width,omitempty"`
+	Height int `json:"height,omitempty" yaml:"height,omitempty"`
+	// DeviceScaleFactor is the number of device pixels per CSS pixel.
+	DeviceScaleFactor float64 `json:"device_scale_factor,omitempty" yaml:"device_scale_factor,omitempty"`
+	// Zoom the page, like the browser's zoom control. 1.5 is 150%.
+	Zoom float64 `json:"zoom,omitempty" yaml:"zoom,omitempty"`
+	// Rotation of the page in degrees clockwise: 0, 90, 180 or 270. Use 90 or 270
+	// for displays mounted in portrait.
+	Rotation int `json:"rotation,omitempty" yaml:"rotation,omitempty"`
+	// UserAgent to present instead of Chromium's own, for sites which serve a
+	// different layout to TVs.
+	UserAgent string `json:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+	// TimeZone to present, as an IANA name like "Europe/Berlin".
+	TimeZone string `json:"time_zone,omitempty" yaml:"time_zone,omitempty"`
+	// Locale to present, like "de-DE". It is also sent as the preferred language.
+	Locale string `json:"locale,omitempty" yaml:"locale,omitempty"`
+	// ColorScheme to prefer, "light" or "dark".
+	ColorScheme string `json:"color_scheme,omitempty" yaml:"color_scheme,omitempty"`
+	// DarkFrom and DarkUntil, as "15:04" in the emulated TimeZone, prefer the
+	// dark color scheme at night. ColorScheme applies the rest of the time.
+	DarkFrom  string `json:"dark_from,omitempty" yaml:"dark_from,omitempty"`
+	DarkUntil string `json:"dark_until,omitempty" yaml:"dark_until,omitempty"`
+	// Geolocation to report to pages which ask for it.
+	Geolocation *Geolocation `json:"geolocation,omitempty" yaml:"geolocation,omitempty"`
+}
+
+// Geolocation of a Screen, in degrees.
+type Geolocation struct {
+	Latitude  float64 `json:"latitude" yaml:"latitude"`
+	Longitude float64 `json:"longitude" yaml:"longitude"`
+	// Accuracy in meters.
+	Accuracy float64 `json:"accuracy,omitempty" yaml:"accuracy,omitempty"`
+}
+
+func (e *Emulation) validate() error {
+	switch e.Rotation {
+	case 0, 90, 180, 270:
+	default:
+		return fmt.Errorf("%w: rotation must be 0, 90, 180 or 270, not %v", ErrInvalidEmulation, e.Rotation)
+	}
+	if e.Width < 0 || e.Height < 0 {
+		return fmt.Errorf("%w: viewport size must not be negative", ErrInvalidEmulation)
+	}
+	if e.DeviceScaleFactor < 0 || e.Zoom < 0 {
+		return fmt.Errorf("%w: scale factor and zoom must not be negative", ErrInvalidEmulation)
+	}
+	switch e.ColorScheme {
+	case "", "light", "dark":
+	default:
+		return fmt.Errorf("%w: color scheme must be light or dark, not %q", ErrInvalidEmulation, e.ColorScheme)
+	}
+	if _, err := e.location(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEmulation, err)
+	}
+	if (e.DarkFrom == "") != (e.DarkUntil == "") {
+		return fmt.Errorf("%w: dark_from and dark_until must be set together", ErrInvalidEmulation)
+	}
+	for _, t := range []string{e.DarkFrom, e.DarkUntil} {
+		if _, err := parseClock(t); t != "" && err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidEmulation, err)
+		}
+	}
+	if g := e.Geolocation; g != nil && (g.Latitude < -90 || g.Latitude > 90 || g.Longitude < -180 || g.Longitude > 180) {
+		return fmt.Errorf("%w: geolocation out of range", ErrInvalidEmulation)
+	}
+	return nil
+}
+
+// location of the emulated TimeZone, or the host's if there is none.
+func (e *Emulation) location() (*time.Location, error) {
+	if e.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(e.TimeZone)
+}
+
+// parseClock parses a time of day as "15:04", returning minutes since midnight.
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("bad time of day %q, want HH:MM", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// scheduled is true if e switches color schemes on a schedule.
+func (e *Emulation) scheduled() bool {
+	return e.DarkFrom != "" && e.DarkUntil != ""
+}
+
+// colorSchemeAt now, taking the dark schedule into account.
+func (e *Emulation) colorSchemeAt(now time.Time) string {
+	if !e.scheduled() {
+		return e.ColorScheme
+	}
+	if loc, err := e.location(); err == nil {
+		now = now.In(loc)
+	}
+	from, _ := parseClock(e.DarkFrom)
+	until, _ := parseClock(e.DarkUntil)
+	minute := now.Hour()*60 + now.Minute()
+	dark := minute >= from && minute < until
+	if from > until {
+		// The dark period spans midnight.
+		dark = minute >= from || minute < until
+	}
+	if dark {
+		return "dark"
+	}
+	if e.ColorScheme == "" {
+		return "light"
+	}
+	return e.ColorScheme
+}
+
+// Emulator is implemented by Screens whose Emulation can be changed while
+// they're running.
+type Emulator interface {
+	// Emulation in effect on the Screen.
+	Emulation() (Emulation, error)
+	// SetEmulation replaces the Emulation in effect on the Screen.
+	SetEmulation(e Emulation) error
+}
+
+// metrics override needed for e on p, or nil if the override should be cleared.
+func (e *Emulation) metrics(p *rod.Page) (*proto.EmulationSetDeviceMetricsOverride, error) {
+	zoom := e.Zoom
+	if zoom == 0 {
+		zoom = 1
+	}
+	if e.Width == 0 && e.Height == 0 && e.DeviceScaleFactor == 0 && zoom == 1 {
+		return nil, nil
+	}
+	width, height := e.Width, e.Height
+	if width == 0 || height == 0 {
+		bounds, err := p.GetWindow()
+		if err != nil {
+			return nil, err
+		}
+		if width == 0 {
+			width = bounds.Width
+		}
+		if height == 0 {
+			height = bounds.Height
+		}
+	}
+	scale := e.DeviceScaleFactor
+	if scale == 0 {
+		scale = 1
+	}
+	// Zooming shrinks the viewport in CSS pixels, and grows each CSS pixel to
+	// fill the same number of device pixels.
+	return &proto.EmulationSetDeviceMetricsOverride{
+		Width:             int(float64(width) / zoom),
+		Height:            int(float64(height) / zoom),
+		DeviceScaleFactor: scale * zoom,
+	}, nil
+}
+
+// rotationCSS turns the root element so that the page fills the display with
+// its top edge on the side given by the rotation.
+var rotationCSS = map[int]string{
+	90:  `html { transform: rotate(90deg) translateY(-100%); transform-origin: top left; width: 100vh !important; height: 100vw !important; overflow: hidden; }`,
+	180: `html { transform: rotate(180deg); transform-origin: center; }`,
+	270: `html { transform: rotate(-90deg) translateX(-100%); transform-origin: top left; width: 100vh !important; height: 100vw !important; overflow: hidden; }`,
+}
+
+const rotationScript = `(() => {
+	const css = %q;
+	const add = () => {
+		let style = document.getElementById('pijector-rotation');
+		if (!style) {
+			style = document.createElement('style');
+			style.id = 'pijector-rotation';
+			(document.head || document.documentElement).appendChild(style);
+		}
+		style.textContent = css;
+	};
+	if (document.readyState === 'loading') {
+		document.addEventListener('DOMContentLoaded', add);
+	} else {
+		add();
+	}
+})()`
+
+const unrotateScript = `() => {
+	const style = document.getElementById('pijector-rotation');
+	if (style) {
+		style.remove();
+	}
+}`
+
+// applyEmulation to p, replacing whatever was in effect before. This function
+// assumes the lock is held before calling.
+func (s *localScreen) applyEmulation(p *rod.Page) error {
+	e := s.emulation
+	metrics, err := e.metrics(p)
+	if err != nil {
+		return err
+	}
+	if err := p.SetViewport(metrics); err != nil {
+		return err
+	}
+	ua := e.UserAgent
+	if ua == "" {
+		// There's no clearing the override, so put back the browser's own.
+		version, err := (proto.BrowserGetVersion{}).Call(p)
+		if err != nil {
+			return err
+		}
+		ua = version.UserAgent
+	}
+	uaOverride := proto.NetworkSetUserAgentOverride{
+		UserAgent:      ua,
+		AcceptLanguage: e.Locale,
+	}
+	if err := uaOverride.Call(p); err != nil {
+		return err
+	}
+	// Chromium refuses to replace one locale override with another, so clear it
+	// first. Empty values clear these overrides.
+	if err := (proto.EmulationSetLocaleOverride{}).Call(p); err != nil {
+		return err
+	}
+	if e.Locale != "" {
+		if err := (proto.EmulationSetLocaleOverride{Locale: e.Locale}).Call(p); err != nil {
+			return err
+		}
+	}
+	if err := (proto.EmulationSetTimezoneOverride{TimezoneID: e.TimeZone}).Call(p); err != nil {
+		return err
+	}
+	if err := s.applyColorScheme(p); err != nil {
+		return err
+	}
+	if g := e.Geolocation; g != nil {
+		grant := proto.BrowserGrantPermissions{
+			Permissions: []proto.BrowserPermissionType{proto.BrowserPermissionTypeGeolocation},
+		}
+		if err := grant.Call(s.browser); err != nil {
+			return err
+		}
+		geo := proto.EmulationSetGeolocationOverride{
+			Latitude:  g.Latitude,
+			Longitude: g.Longitude,
+			Accuracy:  g.Accuracy,
+		}
+		if err := geo.Call(p); err != nil {
+			return err
+		}
+	} else if err := (proto.EmulationClearGeolocationOverride{}).Call(p); err != nil {
+		return err
+	}
+	if s.unrotate != nil {
+		if err := s.unrotate(); err != nil {
+			logrus.WithError(err).WithField("screen", s.id).Debug("removing rotation failed")
+		}
+		s.unrotate = nil
+	}
+	if _, err := p.Timeout(scriptTimeout).Eval(unrotateScript); err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Debug("removing rotation from current document failed")
+	}
+	if css := rotationCSS[e.Rotation]; css != "" {
+		js := fmt.Sprintf(rotationScript, css)
+		remove, err := p.EvalOnNewDocument(js)
+		if err != nil {
+			return err
+		}
+		s.unrotate = remove
+		if _, err := p.Timeout(scriptTimeout).Evaluate(rod.Eval(js)); err != nil {
+			logrus.WithError(err).WithField("screen", s.id).Debug("rotating current document failed")
+		}
+	}
+	return nil
+}
+
+// applyColorScheme in effect right now to p. This function assumes the lock is
+// held before calling.
+func (s *localScreen) applyColorScheme(p *rod.Page) error {
+	scheme := s.emulation.colorSchemeAt(time.Now())
+	media := proto.EmulationSetEmulatedMedia{
+		Features: []*proto.EmulationMediaFeature{{
+			Name:  "prefers-color-scheme",
+			Value: scheme,
+		}},
+	}
+	if err := media.Call(p); err != nil {
+		return err
+	}
+	s.colorScheme = scheme
+	return nil
+}
+
+// colorSchedulePeriod is how often the dark schedule is checked.
+const colorSchedulePeriod = time.Minute
+
+// followColorSchedule switches the color scheme when the dark schedule says
+// so, for as long as the Screen exists. This function assumes the lock is held
+// before calling.
+func (s *localScreen) followColorSchedule() {
+	if s.scheduling || !s.emulation.scheduled() {
+		return
+	}
+	s.scheduling = true
+	go func() {
+		ticker := time.NewTicker(colorSchedulePeriod)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.Lock()
+			if s.current != nil && s.emulation.colorSchemeAt(time.Now()) != s.colorScheme {
+				if err := s.checkConn(s.applyColorScheme(s.current)); err != nil {
+					logrus.WithError(err).WithField("screen", s.id).Warn("switching color scheme failed")
+				}
+			}
+			s.Unlock()
+		}
+	}()
+}
+
+// environmentScript reports what the page sees of the emulated environment.
+const environmentScript = `() => ({
+	timeZone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+	locale: navigator.language,
+	colorScheme: matchMedia('(prefers-color-scheme: dark)').matches ? 'dark' : 'light',
+})`
+
+// statEnvironment fills in the effective time zone, locale and color scheme as
+// seen by the current page. This function assumes the lock is held before
+// calling.
+func (s *localScreen) statEnvironment(stat *ScreenStatus) {
+	stat.Geolocation = s.emulation.Geolocation
+	res, err := s.current.Timeout(scriptTimeout).Eval(environmentScript)
+	if err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Debug("reading page environment failed")
+		stat.TimeZone = s.emulation.TimeZone
+		stat.Locale = s.emulation.Locale
+		stat.ColorScheme = s.colorScheme
+		return
+	}
+	stat.TimeZone = res.Value.Get("timeZone").Str()
+	stat.Locale = res.Value.Get("locale").Str()
+	stat.ColorScheme = res.Value.Get("colorScheme").Str()
+}
+
+func (s *localScreen) Emulation() (Emulation, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.emulation, nil
+}
+
+func (s *localScreen) SetEmulation(e Emulation) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.emulation = e
+	s.followColorSchedule()
+	// Attaching applies the new emulation, so only an existing page needs it.
+	attached := s.current != nil
+	if err := s.attachIfNecessary(); err != nil {
+		return err
+	}
+	if attached {
+		return s.checkConn(s.applyEmulation(s.current))
+	}
+	return nil
+}
+
+func (s *remoteScreen) Emulation() (e Emulation, err error) {
+	err = s.doJSON(http.MethodGet, "/emulation", nil, &e)
+	return
+}
+
+func (s *remoteScreen) SetEmulation(e Emulation) error {
+	return s.doJSON(http.MethodPut, "/emulation", &e, nil)
+}
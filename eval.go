@@ -0,0 +1,107 @@
+package pijector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrEvalTimeout is returned when a JavaScript expression doesn't finish
+	// evaluating in time.
+	ErrEvalTimeout = errors.New("evaluation timed out")
+
+	errEmptyExpression = errors.New("expression is required")
+)
+
+// maxLoggedExpression is how much of an expression is logged, since they may
+// hold tokens or other secrets, and are only logged when debugging.
+const maxLoggedExpression = 64
+
+// loggedExpression is expr cut down to maxLoggedExpression characters.
+func loggedExpression(expr string) string {
+	if r := []rune(expr); len(r) > maxLoggedExpression {
+		return string(r[:maxLoggedExpression]) + "…"
+	}
+	return expr
+}
+
+// EvalResult of a JavaScript expression evaluated on a Screen. If the
+// expression threw, Exception describes what was thrown, and Value is empty.
+type EvalResult struct {
+	Value     json.RawMessage `json:"value,omitempty"`
+	Exception string          `json:"exception,omitempty"`
+}
+
+// Evaluator is implemented by Screens which can evaluate JavaScript in the
+// page they display.
+type Evaluator interface {
+	// Eval a JavaScript expression in the Screen's current page, waiting up to
+	// timeout for it, and for any promise it returns, to finish.
+	Eval(expr string, timeout time.Duration) (EvalResult, error)
+}
+
+func (s *localScreen) Eval(expr string, timeout time.Duration) (EvalResult, error) {
+	if expr == "" {
+		return EvalResult{}, errEmptyExpression
+	}
+	if timeout <= 0 {
+		return EvalResult{}, fmt.Errorf("%w: %v", errInvalidDuration, timeout)
+	}
+	s.Lock()
+	defer s.Unlock()
+	if err := s.attachIfNecessary(); err != nil {
+		return EvalResult{}, err
+	}
+	log := logrus.WithField("screen", s.id)
+	log.WithField("expression", loggedExpression(expr)).Debug("evaluating expression")
+	res, err := s.current.Timeout(timeout).Eval(expr)
+	var thrown *rod.ErrEval
+	switch {
+	case errors.As(err, &thrown):
+		log.Debug("evaluated expression threw")
+		exc := thrown.Exception
+		if exc != nil && exc.Description != "" {
+			return EvalResult{Exception: exc.Description}, nil
+		}
+		return EvalResult{Exception: thrown.Text}, nil
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Warn("evaluating expression timed out")
+		return EvalResult{}, fmt.Errorf("%w after %v", ErrEvalTimeout, timeout)
+	case err != nil:
+		return EvalResult{}, s.checkConn(err)
+	}
+	log.Debug("evaluated expression")
+	value, err := json.Marshal(res.Value)
+	if err != nil {
+		return EvalResult{}, err
+	}
+	return EvalResult{Value: value}, nil
+}
+
+func (s *remoteScreen) Eval(expr string, timeout time.Duration) (res EvalResult, err error) {
+	// The remote Screen may take up to timeout to answer, on top of the usual
+	// time allowed for requests.
+	c := *s.c
+	c.Timeout += timeout
+	slow := *s
+	slow.c = &c
+	path := "/eval?timeout=" + url.QueryEscape(timeout.String())
+	err = slow.doJSONVetted(http.MethodPost, path, map[string]string{"expression": expr}, &res, vetEvalResponse)
+	return
+}
+
+// vetEvalResponse maps the remote server's timeout to ErrEvalTimeout.
+func vetEvalResponse(r *http.Response) error {
+	if r.StatusCode == http.StatusGatewayTimeout {
+		return fmt.Errorf("%w: %v", ErrEvalTimeout, readError(r))
+	}
+	return vetResponse(r)
+}
@@ -0,0 +1,74 @@
+package pijector
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// eventBuffer is how many events a subscriber may fall behind by before it
+// starts missing them.
+const eventBuffer = 16
+
+// ScreenEvent is something noteworthy happening on a Screen.
+type ScreenEvent struct {
+	Time   time.Time `json:"time"`
+	Screen string    `json:"screen"`
+	// Type of the event, like "stale" or "blank".
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// EventSource is implemented by Screens which publish ScreenEvents.
+type EventSource interface {
+	// Events published by the Screen from now until ctx is done, when the
+	// channel is closed. Subscribers which fall behind miss events.
+	Events(ctx context.Context) <-chan ScreenEvent
+}
+
+// eventHub fans ScreenEvents out to subscribers.
+type eventHub struct {
+	sync.Mutex // protects following members
+	subs       map[chan ScreenEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subs: make(map[chan ScreenEvent]struct{}),
+	}
+}
+
+func (h *eventHub) subscribe(ctx context.Context) <-chan ScreenEvent {
+	ch := make(chan ScreenEvent, eventBuffer)
+	h.Lock()
+	h.subs[ch] = struct{}{}
+	h.Unlock()
+	go func() {
+		<-ctx.Done()
+		h.Lock()
+		delete(h.subs, ch)
+		close(ch)
+		h.Unlock()
+	}()
+	return ch
+}
+
+// publish e to every subscriber with room for it.
+func (h *eventHub) publish(e ScreenEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.Lock()
+	defer h.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (s *localScreen) Events(ctx context.Context) <-chan ScreenEvent {
+	return s.events.subscribe(ctx)
+}
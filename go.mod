@@ -8,10 +8,12 @@ require (
 	github.com/go-rod/rod v0.99.1
 	github.com/google/uuid v1.2.0
 	github.com/gorilla/mux v1.8.0
-	github.com/russross/blackfriday/v2 v2.1.0 // indirect
+	github.com/prometheus/client_golang v1.11.0
+	github.com/russross/blackfriday/v2 v2.1.0
 	github.com/sirupsen/logrus v1.8.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/urfave/cli/v2 v2.3.0
 	github.com/ysmood/gson v0.7.0 // indirect
 	golang.org/x/net v0.0.0-20210521195947-fe42d452be8f // indirect
-	gopkg.in/yaml.v2 v2.2.3
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,112 @@
+package pijector
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// DefaultIdentifyDuration is how long a Screen identifies itself for, unless
+	// asked for longer.
+	DefaultIdentifyDuration = 10 * time.Second
+
+	// identifyOverlayID is shared by every identification, so that identifying
+	// a Screen again replaces the last one.
+	identifyOverlayID = "pijector-identify"
+	identifyQRSize    = 256
+)
+
+// Identifier is implemented by Screens which can identify themselves, so that
+// they can be matched to the physical displays they run on.
+type Identifier interface {
+	// Identify the Screen by showing its name, ID and address in huge type on
+	// top of its page for d, along with a QR code of qr unless it is empty.
+	Identify(d time.Duration, qr string) error
+}
+
+// identifyOverlay shows lines of text, and a QR code of qr unless it is empty,
+// for d.
+func identifyOverlay(lines []string, d time.Duration, qr string) (Overlay, error) {
+	expires := time.Now().Add(d)
+	o := Overlay{
+		ID:      identifyOverlayID,
+		Style:   OverlayCard,
+		Size:    "4vw",
+		Expires: &expires,
+	}
+	for i, l := range lines {
+		if i > 0 {
+			o.Text += "\n"
+		}
+		o.Text += l
+	}
+	if qr != "" {
+		png, err := qrcode.Encode(qr, qrcode.Medium, identifyQRSize)
+		if err != nil {
+			return o, fmt.Errorf("%w: can't make QR code: %v", ErrBadOverlay, err)
+		}
+		o.Image = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	}
+	return o, nil
+}
+
+// hostAddress of the machine whose Chromium is at the CDP address addr. That is
+// usually on the loopback address, which is the same for every machine, so
+// then it's this machine's host name and first address which isn't.
+func hostAddress(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if ip := net.ParseIP(host); host != "" && host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return host
+	}
+	hostname, _ := os.Hostname()
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok && !ipn.IP.IsLoopback() && !ipn.IP.IsLinkLocalUnicast() {
+			if hostname == "" {
+				return ipn.IP.String()
+			}
+			return hostname + " " + ipn.IP.String()
+		}
+	}
+	return hostname
+}
+
+func (s *localScreen) Identify(d time.Duration, qr string) error {
+	if d <= 0 {
+		d = DefaultIdentifyDuration
+	}
+	lines := []string{s.id}
+	if host := hostAddress(s.addr); host != "" {
+		lines = append(lines, host)
+	}
+	if s.name != "" {
+		lines = append([]string{s.name}, lines...)
+	}
+	o, err := identifyOverlay(lines, d, qr)
+	if err != nil {
+		return err
+	}
+	_, err = s.AddOverlay(o)
+	return err
+}
+
+func (s *remoteScreen) Identify(d time.Duration, qr string) error {
+	q := url.Values{}
+	if d > 0 {
+		q.Set("duration", d.String())
+	}
+	if qr != "" {
+		q.Set("qr", qr)
+	}
+	return s.doJSON(http.MethodPost, "/identify?"+q.Encode(), nil, nil)
+}
@@ -0,0 +1,206 @@
+package pijector
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/sirupsen/logrus"
+)
+
+var errNoIdleHome = errors.New("idle reset requires a home URL or default URL")
+
+// idleActivityBinding is the name of the function exposed to pages, which the
+// activity script calls whenever a visitor interacts with the page.
+const idleActivityBinding = "pijectorActivity"
+
+// idleActivityScript reports visitor interaction to Pijector. Reports are
+// throttled, since pointer movement in particular is very chatty.
+const idleActivityScript = `(() => {
+	if (window.__pijectorIdleWatch) {
+		return;
+	}
+	window.__pijectorIdleWatch = true;
+	let last = 0;
+	const report = () => {
+		const now = Date.now();
+		if (now - last < 1000) {
+			return;
+		}
+		last = now;
+		try {
+			window.` + idleActivityBinding + `('');
+		} catch (e) {}
+	};
+	for (const t of ['pointerdown', 'pointermove', 'keydown', 'touchstart', 'wheel']) {
+		window.addEventListener(t, report, {capture: true, passive: true});
+	}
+})()`
+
+// idleCountdownScript overlays a countdown warning visitors that the Screen is
+// about to reset. Any interaction removes it.
+const idleCountdownScript = `(seconds) => {
+	let overlay = document.getElementById('pijector-idle-countdown');
+	if (overlay) {
+		overlay.remove();
+	}
+	overlay = document.createElement('div');
+	overlay.id = 'pijector-idle-countdown';
+	overlay.style.cssText = 'position:fixed;top:0;left:0;right:0;bottom:0;z-index:2147483647;' +
+		'display:flex;align-items:center;justify-content:center;text-align:center;' +
+		'background:rgba(0,0,0,.75);color:#eee;font-family:serif;font-size:6vmin;';
+	const render = () => {
+		overlay.textContent = 'Still there? Returning to the start in ' + seconds + '…';
+	};
+	render();
+	const tick = setInterval(() => {
+		seconds = Math.max(seconds - 1, 0);
+		render();
+	}, 1000);
+	const dismiss = () => {
+		clearInterval(tick);
+		overlay.remove();
+		for (const t of ['pointerdown', 'keydown', 'touchstart']) {
+			window.removeEventListener(t, dismiss, true);
+		}
+	};
+	for (const t of ['pointerdown', 'keydown', 'touchstart']) {
+		window.addEventListener(t, dismiss, true);
+	}
+	document.documentElement.appendChild(overlay);
+}`
+
+// idleDismissScript removes the countdown overlay, if it's up.
+const idleDismissScript = `() => {
+	const overlay = document.getElementById('pijector-idle-countdown');
+	if (overlay) {
+		overlay.remove();
+	}
+}`
+
+// idleReset returns a Screen to its home URL once visitors stop interacting
+// with it. The timer is only armed by visitor interaction, so content shown
+// through the API is left alone until someone actually touches the Screen.
+type idleReset struct {
+	home               string
+	timeout, countdown time.Duration
+	reset              func(home string)
+
+	sync.Mutex // protects following members
+	timer      *time.Timer
+	overlaid   *rod.Page
+}
+
+func newIdleReset(home string, timeout, countdown time.Duration, reset func(string)) *idleReset {
+	return &idleReset{
+		home:      home,
+		timeout:   timeout,
+		countdown: countdown,
+		reset:     reset,
+	}
+}
+
+// watch p for visitor interaction. Watching stops when the connection to the
+// page is dropped.
+func (i *idleReset) watch(p *rod.Page) error {
+	if err := (proto.RuntimeAddBinding{Name: idleActivityBinding}).Call(p); err != nil {
+		return err
+	}
+	if _, err := p.EvalOnNewDocument(idleActivityScript); err != nil {
+		return err
+	}
+	// The current document predates the script above, so it needs a copy too.
+	if _, err := p.Evaluate(rod.Eval(idleActivityScript)); err != nil {
+		logrus.WithError(err).Debug("injecting idle watcher into current document failed")
+	}
+	go p.EachEvent(func(e *proto.RuntimeBindingCalled) {
+		if e.Name == idleActivityBinding {
+			i.activity(p)
+		}
+	})()
+	return nil
+}
+
+// activity by a visitor on p (re)starts the idle timer.
+func (i *idleReset) activity(p *rod.Page) {
+	i.Lock()
+	defer i.Unlock()
+	i.stopLocked()
+	i.timer = time.AfterFunc(i.timeout, func() { i.idle(p) })
+}
+
+// idle is called when the timeout passes without activity on p.
+func (i *idleReset) idle(p *rod.Page) {
+	if i.countdown <= 0 {
+		i.expire()
+		return
+	}
+	i.Lock()
+	defer i.Unlock()
+	secs := int(math.Ceil(i.countdown.Seconds()))
+	if _, err := p.Timeout(scriptTimeout).Eval(idleCountdownScript, secs); err != nil {
+		logrus.WithError(err).Debug("showing idle countdown failed")
+	} else {
+		i.overlaid = p
+	}
+	i.timer = time.AfterFunc(i.countdown, i.expire)
+}
+
+func (i *idleReset) expire() {
+	i.Lock()
+	i.timer = nil
+	i.overlaid = nil
+	i.Unlock()
+	i.reset(i.home)
+}
+
+// disarm the idle timer, for example because the Screen was told to show
+// something else.
+func (i *idleReset) disarm() {
+	i.Lock()
+	defer i.Unlock()
+	i.stopLocked()
+}
+
+// stopLocked stops the timer and removes any countdown overlay. This function
+// assumes the lock is held before calling.
+func (i *idleReset) stopLocked() {
+	if i.timer != nil {
+		i.timer.Stop()
+		i.timer = nil
+	}
+	if i.overlaid != nil {
+		if _, err := i.overlaid.Timeout(scriptTimeout).Eval(idleDismissScript); err != nil {
+			logrus.WithError(err).Debug("dismissing idle countdown failed")
+		}
+		i.overlaid = nil
+	}
+}
+
+// goHome is called by the idle reset to return the Screen to home.
+func (s *localScreen) goHome(home string) {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.attachIfNecessary(); err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Warn("idle reset failed")
+		return
+	}
+	if info, err := s.current.Info(); err == nil && info.URL == home {
+		return
+	}
+	s.cancelRevert()
+	if err := s.show(home, PlayIdle); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"target": home,
+			"screen": s.id,
+		}).Warn("idle reset failed")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"target": home,
+		"screen": s.id,
+	}).Info("idle reset")
+}
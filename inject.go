@@ -0,0 +1,221 @@
+package pijector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNoSuchRule is returned when removing an injection rule which doesn't
+	// exist.
+	ErrNoSuchRule = errors.New("no such injection rule")
+
+	errEmptyRule = errors.New("injection rule needs a match pattern and CSS or JavaScript")
+)
+
+// InjectionRule adds CSS and JavaScript to every page displayed on a Screen
+// whose URL matches a pattern. The pattern syntax is the same as for
+// NavigationPolicy.
+type InjectionRule struct {
+	ID    string `json:"id,omitempty" yaml:"id,omitempty"`
+	Match string `json:"match" yaml:"match"`
+	CSS   string `json:"css,omitempty" yaml:"css,omitempty"`
+	JS    string `json:"js,omitempty" yaml:"js,omitempty"`
+}
+
+// Injector is implemented by Screens which can inject CSS and JavaScript into
+// the pages they display.
+type Injector interface {
+	// InjectionRules in effect on the Screen.
+	InjectionRules() ([]InjectionRule, error)
+	// AddInjectionRule to the Screen, returning it with its ID set. Rules take
+	// effect immediately, and on every later navigation.
+	AddInjectionRule(r InjectionRule) (InjectionRule, error)
+	// RemoveInjectionRule by ID.
+	RemoveInjectionRule(id string) error
+}
+
+// injectionScript wraps a rule so that it only takes effect on matching pages.
+// It runs before the page's own scripts, so the CSS and JS wait until there is
+// a document to work with.
+const injectionScript = `(() => {
+	const rule = %v;
+	if (!new RegExp(rule.match).test(location.href)) {
+		return;
+	}
+	const ready = (fn) => {
+		if (document.readyState === 'loading') {
+			document.addEventListener('DOMContentLoaded', fn);
+		} else {
+			fn();
+		}
+	};
+	if (rule.css) {
+		ready(() => {
+			const style = document.createElement('style');
+			style.dataset.pijectorRule = rule.id;
+			style.textContent = rule.css;
+			(document.head || document.documentElement).appendChild(style);
+		});
+	}
+	if (rule.js) {
+		ready(() => {
+			try {
+				(0, eval)(rule.js);
+			} catch (e) {
+				console.error('pijector injection rule ' + rule.id + ' failed:', e);
+			}
+		});
+	}
+})()`
+
+func (r *InjectionRule) scriptID() string {
+	return r.ID
+}
+
+func (r *InjectionRule) script() (string, error) {
+	re, err := compileURLPattern(r.Match)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(map[string]string{
+		"id":    r.ID,
+		"match": re.String(),
+		"css":   r.CSS,
+		"js":    r.JS,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(injectionScript, string(data)), nil
+}
+
+// injections manages the injection rules of a local Screen, and their
+// registration with its current page.
+type injections struct {
+	sync.Mutex // protects following members
+	rules      []InjectionRule
+	scripts    pageScripts
+}
+
+func newInjections(rules []InjectionRule) (*injections, error) {
+	inj := &injections{scripts: pageScripts{field: "rule"}}
+	for _, r := range rules {
+		if _, err := inj.add(r); err != nil {
+			return nil, err
+		}
+	}
+	return inj, nil
+}
+
+func (inj *injections) list() []InjectionRule {
+	inj.Lock()
+	defer inj.Unlock()
+	return append([]InjectionRule(nil), inj.rules...)
+}
+
+func (inj *injections) add(r InjectionRule) (InjectionRule, error) {
+	if r.Match == "" || (r.CSS == "" && r.JS == "") {
+		return r, errEmptyRule
+	}
+	if r.ID == "" {
+		r.ID = uuid.NewString()
+	}
+	if _, err := r.script(); err != nil {
+		return r, err
+	}
+	inj.Lock()
+	defer inj.Unlock()
+	for i, existing := range inj.rules {
+		if existing.ID == r.ID {
+			inj.scripts.unregister(r.ID)
+			inj.rules = append(inj.rules[:i], inj.rules[i+1:]...)
+			break
+		}
+	}
+	inj.rules = append(inj.rules, r)
+	if inj.scripts.attached() {
+		if err := inj.scripts.register(&r, true); err != nil {
+			// The rule still applies from the next time a page is set up.
+			logrus.WithError(err).WithField("rule", r.ID).Warn("registering injection failed")
+		}
+	}
+	return r, nil
+}
+
+func (inj *injections) remove(id string) error {
+	inj.Lock()
+	defer inj.Unlock()
+	for i, r := range inj.rules {
+		if r.ID == id {
+			inj.scripts.unregister(id)
+			inj.rules = append(inj.rules[:i], inj.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrNoSuchRule, id)
+}
+
+// apply all rules to p, which has become the Screen's page.
+func (inj *injections) apply(p *rod.Page) {
+	inj.Lock()
+	defer inj.Unlock()
+	scripts := make([]pageScript, 0, len(inj.rules))
+	for i := range inj.rules {
+		scripts = append(scripts, &inj.rules[i])
+	}
+	inj.scripts.apply(p, scripts)
+}
+
+// detach from the page, which is no longer usable.
+func (inj *injections) detach() {
+	inj.Lock()
+	defer inj.Unlock()
+	inj.scripts.detach()
+}
+
+func (s *localScreen) InjectionRules() ([]InjectionRule, error) {
+	return s.inject.list(), nil
+}
+
+func (s *localScreen) AddInjectionRule(r InjectionRule) (InjectionRule, error) {
+	s.Lock()
+	defer s.Unlock()
+	// Attaching applies existing rules to the page, so only the new rule needs
+	// registering.
+	if err := s.attachIfNecessary(); err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Debug("rule will apply once attached")
+	}
+	return s.inject.add(r)
+}
+
+func (s *localScreen) RemoveInjectionRule(id string) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.inject.remove(id)
+}
+
+func (s *remoteScreen) InjectionRules() (rules []InjectionRule, err error) {
+	err = s.doJSON(http.MethodGet, "/inject", nil, &rules)
+	return
+}
+
+func (s *remoteScreen) AddInjectionRule(r InjectionRule) (added InjectionRule, err error) {
+	err = s.doJSON(http.MethodPost, "/inject", &r, &added)
+	return
+}
+
+func (s *remoteScreen) RemoveInjectionRule(id string) error {
+	err := s.doJSON(http.MethodDelete, "/inject/"+id, nil, nil)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %v", ErrNoSuchRule, id)
+	}
+	return err
+}
@@ -0,0 +1,358 @@
+package pijector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+var (
+	// ErrInvalidInput is returned when asked to send Input which doesn't make
+	// sense.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrNoSuchElement is returned when there is nothing on the page matching
+	// the selector of a ClickInput.
+	ErrNoSuchElement = errors.New("no such element")
+)
+
+// Input for a Screen, as if someone were standing at it with a mouse and a
+// keyboard. Exactly one member should be set.
+type Input struct {
+	Click *ClickInput `json:"click,omitempty"`
+	// Text to type into whatever has focus.
+	Text string `json:"text,omitempty"`
+	// Keys to press together, like "ArrowRight", "Enter" or "Control+Shift+R".
+	Keys   string       `json:"keys,omitempty"`
+	Scroll *ScrollInput `json:"scroll,omitempty"`
+}
+
+// ClickInput clicks on an element, or at a point on the Screen.
+type ClickInput struct {
+	// Selector of the element to click. If set, X and Y are ignored.
+	Selector string `json:"selector,omitempty"`
+	// X and Y of the point to click, in CSS pixels from the top left of the
+	// viewport. If Relative is set, they are instead fractions of the
+	// viewport's width and height, so that 0.5, 0.5 is the middle of the Screen.
+	X        float64 `json:"x,omitempty"`
+	Y        float64 `json:"y,omitempty"`
+	Relative bool    `json:"relative,omitempty"`
+	// Button to click: "left" (the default), "middle" or "right".
+	Button string `json:"button,omitempty"`
+	// Count of clicks, 2 for a double click. Defaults to 1.
+	Count int `json:"count,omitempty"`
+}
+
+// ScrollInput scrolls the page, as a mouse wheel would.
+type ScrollInput struct {
+	// DX and DY to scroll by, in CSS pixels. Positive DY scrolls down.
+	DX float64 `json:"dx,omitempty"`
+	DY float64 `json:"dy,omitempty"`
+}
+
+// Inputter is implemented by Screens which accept Input.
+type Inputter interface {
+	// SendInput to the page displayed on the Screen.
+	SendInput(in Input) error
+}
+
+func (in *Input) validate() error {
+	set := 0
+	if in.Click != nil {
+		set++
+	}
+	if in.Text != "" {
+		set++
+	}
+	if in.Keys != "" {
+		set++
+	}
+	if in.Scroll != nil {
+		set++
+	}
+	if set != 1 {
+		return fmt.Errorf("%w: exactly one of click, text, keys or scroll must be set", ErrInvalidInput)
+	}
+	if in.Click != nil {
+		if _, err := mouseButton(in.Click.Button); err != nil {
+			return err
+		}
+	}
+	if in.Keys != "" {
+		if _, err := parseKeyChord(in.Keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputTimeout bounds how long an Input may take, including waiting for an
+// element to click on to appear.
+const inputTimeout = 5 * time.Second
+
+func mouseButton(name string) (proto.InputMouseButton, error) {
+	switch strings.ToLower(name) {
+	case "", "left":
+		return proto.InputMouseButtonLeft, nil
+	case "middle":
+		return proto.InputMouseButtonMiddle, nil
+	case "right":
+		return proto.InputMouseButtonRight, nil
+	}
+	return "", fmt.Errorf("%w: unknown mouse button %q", ErrInvalidInput, name)
+}
+
+// keyModifiers are the bits for modifier keys in CDP key and mouse events.
+var keyModifiers = map[string]struct {
+	bit int
+	key rune
+}{
+	"alt":     {1, input.Alt},
+	"control": {2, input.Control},
+	"ctrl":    {2, input.Control},
+	"meta":    {4, input.Meta},
+	"cmd":     {4, input.Meta},
+	"shift":   {8, input.Shift},
+}
+
+// keyChord is a key pressed while holding modifiers.
+type keyChord struct {
+	modifiers []rune
+	bits      int
+	key       rune
+}
+
+// lookupKey by a single character, or by its DOM key or code name, like "Enter",
+// "ArrowRight" or "KeyA".
+func lookupKey(name string) (rune, bool) {
+	if utf8.RuneCountInString(name) == 1 {
+		// Single characters are taken literally, so that "A" isn't "a".
+		r, _ := utf8.DecodeRuneInString(name)
+		_, ok := input.Keys[r]
+		return r, ok
+	}
+	for r, k := range input.Keys {
+		if strings.EqualFold(k.Key, name) || strings.EqualFold(k.Code, name) {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+func parseKeyChord(chord string) (*keyChord, error) {
+	parts := strings.Split(chord, "+")
+	kc := &keyChord{}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if i < len(parts)-1 {
+			mod, ok := keyModifiers[strings.ToLower(part)]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown modifier %q", ErrInvalidInput, part)
+			}
+			kc.modifiers = append(kc.modifiers, mod.key)
+			kc.bits |= mod.bit
+			continue
+		}
+		key, ok := lookupKey(part)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidInput, part)
+		}
+		kc.key = key
+	}
+	return kc, nil
+}
+
+// keyEvent for r of type t, with the modifier bits held.
+func keyEvent(t proto.InputDispatchKeyEventType, r rune, bits int) *proto.InputDispatchKeyEvent {
+	k := input.Keys[r]
+	return &proto.InputDispatchKeyEvent{
+		Type:                  t,
+		Modifiers:             bits,
+		Key:                   k.Key,
+		Code:                  k.Code,
+		WindowsVirtualKeyCode: k.Windows,
+	}
+}
+
+// press the chord on p: modifiers down in order, the key down and up, and the
+// modifiers up in reverse order.
+func (kc *keyChord) press(p *rod.Page) error {
+	held := 0
+	for _, m := range kc.modifiers {
+		held |= keyModifiers[strings.ToLower(input.Keys[m].Key)].bit
+		if err := keyEvent(proto.InputDispatchKeyEventTypeRawKeyDown, m, held).Call(p); err != nil {
+			return err
+		}
+	}
+	k, bits := input.Keys[kc.key], kc.bits
+	if k.Shift {
+		// Characters like "A" and "?" are typed with shift held.
+		bits |= keyModifiers["shift"].bit
+	}
+	down := keyEvent(proto.InputDispatchKeyEventTypeRawKeyDown, kc.key, bits)
+	if k.Print && bits&^keyModifiers["shift"].bit == 0 {
+		// Printable keys without shortcut modifiers produce text.
+		down.Type = proto.InputDispatchKeyEventTypeKeyDown
+		down.Text = k.Text
+		down.UnmodifiedText = k.Unmodified
+	}
+	if err := down.Call(p); err != nil {
+		return err
+	}
+	if err := keyEvent(proto.InputDispatchKeyEventTypeKeyUp, kc.key, bits).Call(p); err != nil {
+		return err
+	}
+	for i := len(kc.modifiers) - 1; i >= 0; i-- {
+		m := kc.modifiers[i]
+		held &^= keyModifiers[strings.ToLower(input.Keys[m].Key)].bit
+		if err := keyEvent(proto.InputDispatchKeyEventTypeKeyUp, m, held).Call(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// viewportSize of p in CSS pixels.
+func viewportSize(p *rod.Page) (float64, float64, error) {
+	metrics, err := (proto.PageGetLayoutMetrics{}).Call(p)
+	if err != nil {
+		return 0, 0, err
+	}
+	vp := metrics.CSSLayoutViewport
+	if vp == nil {
+		vp = metrics.LayoutViewport
+	}
+	return float64(vp.ClientWidth), float64(vp.ClientHeight), nil
+}
+
+func click(p *rod.Page, c *ClickInput) error {
+	button, err := mouseButton(c.Button)
+	if err != nil {
+		return err
+	}
+	x, y := c.X, c.Y
+	if c.Selector != "" {
+		el, err := p.Element(c.Selector)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %v", ErrNoSuchElement, c.Selector)
+		}
+		if err != nil {
+			return err
+		}
+		// Click the element where it can be clicked, as if at a point, so that
+		// Count applies to it too.
+		pt, err := el.WaitInteractable()
+		if err != nil {
+			return err
+		}
+		if err := el.WaitEnabled(); err != nil {
+			return err
+		}
+		x, y = pt.X, pt.Y
+	} else if c.Relative {
+		w, h, err := viewportSize(p)
+		if err != nil {
+			return err
+		}
+		x, y = x*w, y*h
+	}
+	count := c.Count
+	if count < 1 {
+		count = 1
+	}
+	for _, t := range []proto.InputDispatchMouseEventType{
+		proto.InputDispatchMouseEventTypeMouseMoved,
+		proto.InputDispatchMouseEventTypeMousePressed,
+		proto.InputDispatchMouseEventTypeMouseReleased,
+	} {
+		ev := proto.InputDispatchMouseEvent{
+			Type: t,
+			X:    x,
+			Y:    y,
+		}
+		if t != proto.InputDispatchMouseEventTypeMouseMoved {
+			ev.Button = button
+			ev.ClickCount = count
+		}
+		if err := ev.Call(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scroll(p *rod.Page, sc *ScrollInput) error {
+	w, h, err := viewportSize(p)
+	if err != nil {
+		return err
+	}
+	return proto.InputDispatchMouseEvent{
+		Type:   proto.InputDispatchMouseEventTypeMouseWheel,
+		X:      w / 2,
+		Y:      h / 2,
+		DeltaX: sc.DX,
+		DeltaY: sc.DY,
+	}.Call(p)
+}
+
+// sendInput to p.
+func sendInput(p *rod.Page, in *Input) error {
+	switch {
+	case in.Click != nil:
+		return click(p, in.Click)
+	case in.Text != "":
+		return proto.InputInsertText{Text: in.Text}.Call(p)
+	case in.Keys != "":
+		kc, err := parseKeyChord(in.Keys)
+		if err != nil {
+			return err
+		}
+		return kc.press(p)
+	case in.Scroll != nil:
+		return scroll(p, in.Scroll)
+	}
+	return ErrInvalidInput
+}
+
+func (s *localScreen) SendInput(in Input) error {
+	if err := in.validate(); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if err := s.attachIfNecessary(); err != nil {
+		return err
+	}
+	err := sendInput(s.current.Timeout(inputTimeout), &in)
+	if errors.Is(err, ErrInvalidInput) || errors.Is(err, ErrNoSuchElement) {
+		return err
+	}
+	return s.checkConn(err)
+}
+
+func (s *remoteScreen) SendInput(in Input) error {
+	return s.doJSONVetted(http.MethodPost, "/input", &in, nil, vetInputResponse)
+}
+
+// vetInputResponse is vetResponse for input requests, which recognizes a remote
+// Screen refusing the input, or finding nothing to click. Any other 404 means
+// the remote has no input endpoint at all.
+func vetInputResponse(r *http.Response) error {
+	switch r.StatusCode {
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %v", ErrInvalidInput, readError(r))
+	case http.StatusNotFound:
+		msg := readError(r)
+		if strings.Contains(msg, ErrNoSuchElement.Error()) {
+			return fmt.Errorf("%w: %v", ErrNoSuchElement, msg)
+		}
+	}
+	return vetResponse(r)
+}
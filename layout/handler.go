@@ -0,0 +1,104 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Prefix under which layouts are served.
+const Prefix = "/layouts/"
+
+// page shows a layout as a CSS grid with a frame per region. The grid is set up
+// through the CSS object model, since the template escaper refuses values such
+// as grid areas in stylesheets.
+var page = template.Must(template.New("layout").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+html, body {
+	margin: 0;
+	height: 100%;
+	overflow: hidden;
+	background: #000;
+}
+#layout {
+	display: grid;
+	width: 100vw;
+	height: 100vh;
+}
+#layout iframe {
+	display: block;
+	width: 100%;
+	height: 100%;
+	border: 0;
+	background: #fff;
+}
+</style>
+</head>
+<body>
+<div id="layout"></div>
+<script>
+((layout) => {
+	const grid = document.getElementById('layout');
+	grid.style.gridTemplateColumns = layout.columns || '';
+	grid.style.gridTemplateRows = layout.rows || '';
+	grid.style.gap = layout.gap || '';
+	if (layout.background) {
+		grid.style.background = layout.background;
+	}
+	layout.regions.forEach((region) => {
+		const frame = document.createElement('iframe');
+		frame.allow = 'autoplay; fullscreen';
+		frame.style.gridArea = region.area || '';
+		grid.appendChild(frame);
+		let current = 0;
+		const load = () => {
+			frame.src = region.urls[current];
+		};
+		load();
+		if (region.urls.length > 1 && region.interval) {
+			setInterval(() => {
+				current = (current + 1) % region.urls.length;
+				load();
+			}, region.interval * 1000);
+		}
+		if (region.refresh) {
+			setInterval(load, region.refresh * 1000);
+		}
+	});
+})({{.}});
+</script>
+</body>
+</html>
+`))
+
+// Handler serves each layout as a composite page at Prefix/{name}.
+func (ls *Layouts) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, Prefix)
+		l, err := ls.Get(name)
+		if errors.Is(err, ErrNoSuchLayout) || errors.Is(err, ErrInvalidName) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "couldn't open layout: %v", err)
+			logrus.WithError(err).WithField("layout", name).Error("opening layout failed")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// Screens must pick up changes to the layout on their next load.
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := page.Execute(w, l.grid()); err != nil {
+			logrus.WithError(err).WithField("layout", name).Error("rendering layout failed")
+		}
+	})
+}
@@ -0,0 +1,309 @@
+// Package layout splits pijector screens into regions, each showing its own
+// URLs, by serving composite pages built from layout templates.
+package layout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNoSuchLayout is returned when asked for a layout which hasn't been
+	// saved.
+	ErrNoSuchLayout = errors.New("no such layout")
+	// ErrInvalidName is returned for layout names which can't be used in paths.
+	ErrInvalidName = errors.New("invalid layout name")
+	// ErrInvalidLayout is returned when saving a layout which can't be shown.
+	ErrInvalidLayout = errors.New("invalid layout")
+
+	errNoLayoutsDir = errors.New("layouts need a directory")
+)
+
+const (
+	// layoutExt of the file in which each layout is kept.
+	layoutExt = ".json"
+	// defaultInterval in seconds between the URLs of a region's playlist.
+	defaultInterval = 30
+)
+
+// validName of a layout, which must be usable as a path segment in URLs.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// validCSS matches the CSS values layouts are built from, such as "1fr 2fr" or
+// "1 / 1 / 3 / 3". It only catches mistakes, since values are applied through
+// the CSS object model rather than pasted into a stylesheet.
+var validCSS = regexp.MustCompile(`^[A-Za-z0-9 .,%/()#+*-]*$`)
+
+func checkName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// layoutTemplate of a layout is its CSS grid, and the grid area of each region.
+type layoutTemplate struct {
+	columns, rows string
+	areas         []string
+}
+
+// templates by name. Layouts without one are custom, built from their own
+// columns and rows, with regions placed in their own areas, or else in turn.
+var templates = map[string]layoutTemplate{
+	"single":              {"1fr", "1fr", []string{"1 / 1"}},
+	"2x2":                 {"1fr 1fr", "1fr 1fr", []string{"1 / 1", "1 / 2", "2 / 1", "2 / 2"}},
+	"side-by-side":        {"1fr 1fr", "1fr", []string{"1 / 1", "1 / 2"}},
+	"stacked":             {"1fr", "1fr 1fr", []string{"1 / 1", "2 / 1"}},
+	"main-sidebar":        {"3fr 1fr", "1fr", []string{"1 / 1", "1 / 2"}},
+	"main-sidebar-ticker": {"3fr 1fr", "1fr 12vh", []string{"1 / 1", "1 / 2", "2 / 1 / 3 / 3"}},
+}
+
+// Templates available to layouts, by name.
+func Templates() []string {
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Region of a layout, showing a playlist of URLs in turn.
+type Region struct {
+	// URLs shown in the region. Paths are served by the pijector server, such as
+	// those of media, apps and messages.
+	URLs []string `json:"urls"`
+	// Interval in seconds between the URLs, if there is more than one.
+	Interval int `json:"interval,omitempty"`
+	// Refresh in seconds, after which the URL shown is reloaded. Zero never
+	// reloads it.
+	Refresh int `json:"refresh,omitempty"`
+	// Area is the CSS grid-area of the region, overriding the template's.
+	Area string `json:"area,omitempty"`
+}
+
+// Layout splits a screen into regions.
+type Layout struct {
+	Name string `json:"name"`
+	// Template of the layout, or empty for a custom layout.
+	Template string `json:"template,omitempty"`
+	// Columns and Rows are the CSS grid-template-columns and grid-template-rows
+	// of the layout, overriding the template's.
+	Columns string `json:"columns,omitempty"`
+	Rows    string `json:"rows,omitempty"`
+	// Gap between regions, and the Background showing through it, in CSS.
+	Gap        string   `json:"gap,omitempty"`
+	Background string   `json:"background,omitempty"`
+	Regions    []Region `json:"regions"`
+	// URL path at which the layout is served.
+	URL string `json:"url,omitempty"`
+}
+
+// checkURL is one a region can show: an absolute http or https URL, or a path
+// on the pijector server.
+func checkURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+	switch {
+	case parsed.Scheme == "http", parsed.Scheme == "https":
+	case parsed.Scheme == "" && parsed.Host == "" && strings.HasPrefix(parsed.Path, "/"):
+	default:
+		return fmt.Errorf("%w: can't show %q", ErrInvalidLayout, u)
+	}
+	return nil
+}
+
+// check that the layout can be shown, filling in its defaults.
+func (l *Layout) check() error {
+	if err := checkName(l.Name); err != nil {
+		return err
+	}
+	var tmpl layoutTemplate
+	if l.Template != "" {
+		var ok bool
+		if tmpl, ok = templates[l.Template]; !ok {
+			return fmt.Errorf("%w: unknown template %q", ErrInvalidLayout, l.Template)
+		}
+		if len(l.Regions) > len(tmpl.areas) {
+			return fmt.Errorf("%w: template %v has only %v regions", ErrInvalidLayout, l.Template, len(tmpl.areas))
+		}
+	}
+	if len(l.Regions) == 0 {
+		return fmt.Errorf("%w: no regions", ErrInvalidLayout)
+	}
+	for _, css := range []string{l.Columns, l.Rows, l.Gap, l.Background} {
+		if !validCSS.MatchString(css) {
+			return fmt.Errorf("%w: bad CSS %q", ErrInvalidLayout, css)
+		}
+	}
+	for i := range l.Regions {
+		r := &l.Regions[i]
+		if len(r.URLs) == 0 {
+			return fmt.Errorf("%w: region %v has no URLs", ErrInvalidLayout, i+1)
+		}
+		for _, u := range r.URLs {
+			if err := checkURL(u); err != nil {
+				return err
+			}
+		}
+		if r.Interval < 0 || r.Refresh < 0 {
+			return fmt.Errorf("%w: region %v has a negative interval or refresh", ErrInvalidLayout, i+1)
+		}
+		if len(r.URLs) > 1 && r.Interval == 0 {
+			r.Interval = defaultInterval
+		}
+		if !validCSS.MatchString(r.Area) {
+			return fmt.Errorf("%w: bad CSS %q", ErrInvalidLayout, r.Area)
+		}
+	}
+	return nil
+}
+
+// grid the layout is shown in, with the template's defaults applied.
+func (l *Layout) grid() *Layout {
+	g := *l
+	g.Regions = append([]Region(nil), l.Regions...)
+	tmpl := templates[l.Template]
+	if g.Columns == "" {
+		g.Columns = tmpl.columns
+	}
+	if g.Rows == "" {
+		g.Rows = tmpl.rows
+	}
+	for i := range g.Regions {
+		if g.Regions[i].Area == "" && i < len(tmpl.areas) {
+			g.Regions[i].Area = tmpl.areas[i]
+		}
+	}
+	return &g
+}
+
+// Layouts kept in a directory, as a JSON file each.
+type Layouts struct {
+	dir string
+
+	sync.Mutex // serializes changes to layouts
+}
+
+// Open the layouts in dir, creating it if necessary.
+func Open(dir string) (*Layouts, error) {
+	if dir == "" {
+		return nil, errNoLayoutsDir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Layouts{dir: dir}, nil
+}
+
+// Path at which the layout with name is served.
+func Path(name string) string {
+	return Prefix + name
+}
+
+func (ls *Layouts) file(name string) string {
+	return filepath.Join(ls.dir, name+layoutExt)
+}
+
+// Get the layout with name.
+func (ls *Layouts) Get(name string) (*Layout, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(ls.file(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %v", ErrNoSuchLayout, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var l Layout
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("layout %v is corrupt: %w", name, err)
+	}
+	l.Name = name
+	l.URL = Path(name)
+	return &l, nil
+}
+
+// List the layouts, by name.
+func (ls *Layouts) List() ([]Layout, error) {
+	files, err := ioutil.ReadDir(ls.dir)
+	if err != nil {
+		return nil, err
+	}
+	list := []Layout{}
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), layoutExt)
+		if f.IsDir() || name == f.Name() || checkName(name) != nil {
+			continue
+		}
+		l, err := ls.Get(name)
+		if err != nil {
+			continue
+		}
+		list = append(list, *l)
+	}
+	return list, nil
+}
+
+// Save l, replacing any layout with the same name. Screens showing it pick up
+// the change on their next load.
+func (ls *Layouts) Save(l Layout) (*Layout, error) {
+	l.URL = ""
+	if err := l.check(); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(&l, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	ls.Lock()
+	defer ls.Unlock()
+	// Write to a temporary file first, so that a half-written layout is never
+	// served.
+	tmp, err := ioutil.TempFile(ls.dir, ".layout-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), ls.file(l.Name)); err != nil {
+		return nil, err
+	}
+	return ls.Get(l.Name)
+}
+
+// Delete the layout with name.
+func (ls *Layouts) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	ls.Lock()
+	defer ls.Unlock()
+	err := os.Remove(ls.file(name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %v", ErrNoSuchLayout, name)
+	}
+	return err
+}
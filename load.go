@@ -0,0 +1,143 @@
+package pijector
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrLoadFailed matches every LoadError, for use with errors.Is.
+var ErrLoadFailed = errors.New("page failed to load")
+
+// LoadError is returned by a local Screen's Show when the page it was told to
+// show couldn't be loaded, leaving Chromium displaying an error page.
+type LoadError struct {
+	URL string
+	// Status of the HTTP response for the page, if it answered with an error.
+	Status int
+	// NetError from Chromium, like "net::ERR_NAME_NOT_RESOLVED", if the page
+	// didn't answer at all.
+	NetError string
+}
+
+func (e *LoadError) Error() string {
+	if e.NetError != "" {
+		return fmt.Sprintf("%v: %v: %v", ErrLoadFailed, e.URL, e.NetError)
+	}
+	return fmt.Sprintf("%v: %v: HTTP status %v", ErrLoadFailed, e.URL, e.Status)
+}
+
+// Is ErrLoadFailed.
+func (e *LoadError) Is(target error) bool {
+	return target == ErrLoadFailed
+}
+
+// LoadRetry decides what a local Screen does when a page it was told to show
+// fails to load.
+type LoadRetry struct {
+	// Retries of the failed page before giving up on it.
+	Retries int
+	// Backoff before the first retry, doubling for each retry after it. Defaults
+	// to 5 seconds.
+	Backoff time.Duration
+	// FallbackURL shown once every retry has failed. If empty, the Screen is
+	// left as it is.
+	FallbackURL string
+}
+
+const (
+	defaultRetryBackoff = 5 * time.Second
+	maxRetryBackoff     = 5 * time.Minute
+)
+
+// backoff before retry number attempt, counting from 0.
+func (r *LoadRetry) backoff(attempt int) time.Duration {
+	d := r.Backoff
+	if d <= 0 {
+		d = defaultRetryBackoff
+	}
+	for i := 0; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d
+}
+
+// pendingRetry of a page which failed to load.
+type pendingRetry struct {
+	target  string
+	attempt int
+	timer   *time.Timer
+}
+
+func newPendingRetry(target string, attempt int, d time.Duration, fn func(*pendingRetry)) *pendingRetry {
+	r := &pendingRetry{
+		target:  target,
+		attempt: attempt,
+	}
+	r.timer = time.AfterFunc(d, func() { fn(r) })
+	return r
+}
+
+// cancelRetry stops any pending retry. This function assumes the lock is held
+// before calling.
+func (s *localScreen) cancelRetry() {
+	if s.retrying != nil {
+		s.retrying.timer.Stop()
+		s.retrying = nil
+	}
+}
+
+// retryIfFailed schedules another attempt at showing u if err says it failed
+// to load, or gives up and shows the fallback once there have been enough
+// attempts. This function assumes the lock is held before calling.
+func (s *localScreen) retryIfFailed(u string, err error, attempt int) {
+	if s.retry == nil || !errors.Is(err, ErrLoadFailed) {
+		return
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"target":  u,
+		"screen":  s.id,
+		"attempt": attempt + 1,
+	})
+	if attempt < s.retry.Retries {
+		d := s.retry.backoff(attempt)
+		log.WithError(err).WithField("backoff", d).Warn("page failed to load, will retry")
+		s.retrying = newPendingRetry(u, attempt+1, d, s.retryTo)
+		return
+	}
+	fallback := s.retry.FallbackURL
+	if fallback == "" || fallback == u {
+		log.WithError(err).Warn("page failed to load, giving up")
+		return
+	}
+	log.WithError(err).WithField("fallback", fallback).Warn("page failed to load, falling back")
+	if err := s.load(fallback, PlayFallback); err != nil {
+		log.WithError(err).Warn("fallback failed")
+	}
+}
+
+// retryTo the target of r, if it is still pending.
+func (s *localScreen) retryTo(r *pendingRetry) {
+	s.Lock()
+	defer s.Unlock()
+	if s.retrying != r {
+		// Superseded by another Show while the timer was firing.
+		return
+	}
+	s.retrying = nil
+	err := s.load(r.target, PlayRetry)
+	if err == nil {
+		logrus.WithFields(logrus.Fields{
+			"target":  r.target,
+			"screen":  s.id,
+			"attempt": r.attempt + 1,
+		}).Info("page loaded on retry")
+		return
+	}
+	s.retryIfFailed(r.target, err, r.attempt)
+}
@@ -0,0 +1,302 @@
+package pijector
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotAllowed is returned when a Screen's navigation policy forbids a URL.
+var ErrNotAllowed = errors.New("navigation not allowed")
+
+// NavigationPolicy restricts the URLs a Screen may display. Patterns match
+// whole URLs, and "*" in a pattern matches any run of characters. For example,
+// "https://*.example.com/*" matches every page on every subdomain of
+// example.com over HTTPS.
+type NavigationPolicy struct {
+	// Allow patterns. If any are set, only matching URLs may be displayed.
+	Allow []string
+	// Deny patterns. Matching URLs are never displayed, even if allowed.
+	Deny []string
+	// BlockedURL to which blocked navigations are redirected, with the blocked
+	// URL in its "url" query parameter. If empty, a built-in page is shown.
+	BlockedURL string
+	// Framing pages, given as URL prefixes like "http://localhost:9292/layouts/",
+	// are served on the Screen's behalf and frame other pages, such as the
+	// pijector server's layouts and apps. Frames within them are held to the
+	// policy too, except those from the framing page's own origin. Frames within
+	// other pages are left to those pages.
+	Framing []string
+}
+
+// navPolicy is a compiled NavigationPolicy.
+type navPolicy struct {
+	allow, deny []*regexp.Regexp
+	blockedURL  string
+	framing     []*url.URL
+}
+
+func compileURLPattern(pattern string) (*regexp.Regexp, error) {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+}
+
+func compileURLPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := compileURLPattern(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad URL pattern %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func newNavPolicy(p *NavigationPolicy) (*navPolicy, error) {
+	allow, err := compileURLPatterns(p.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := compileURLPatterns(p.Deny)
+	if err != nil {
+		return nil, err
+	}
+	var framing []*url.URL
+	for _, f := range p.Framing {
+		u, err := url.Parse(f)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("bad framing page %q", f)
+		}
+		framing = append(framing, u)
+	}
+	return &navPolicy{
+		allow:      allow,
+		deny:       deny,
+		blockedURL: p.BlockedURL,
+		framing:    framing,
+	}, nil
+}
+
+func matchesAny(res []*regexp.Regexp, u string) bool {
+	for _, re := range res {
+		if re.MatchString(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows is true if the policy permits displaying u. Safe to call on a nil
+// navPolicy, which allows everything.
+func (n *navPolicy) allows(u string) bool {
+	if n == nil || strings.HasPrefix(u, "about:") {
+		return true
+	}
+	if n.isBlockedPage(u) {
+		return true
+	}
+	if matchesAny(n.deny, u) {
+		return false
+	}
+	return len(n.allow) == 0 || matchesAny(n.allow, u)
+}
+
+// isBlockedPage is true if u is the blocked URL, whatever its query, which
+// fulfillBlocked adds to. Safe to call on a nil navPolicy.
+func (n *navPolicy) isBlockedPage(u string) bool {
+	if n == nil || n.blockedURL == "" {
+		return false
+	}
+	blocked, err := url.Parse(n.blockedURL)
+	if err != nil {
+		return false
+	}
+	target, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(target.Scheme, blocked.Scheme) &&
+		strings.EqualFold(target.Host, blocked.Host) &&
+		cleanPath(target.Path) == cleanPath(blocked.Path)
+}
+
+// cleanPath treats an empty URL path as the root.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+// enforce the policy on top-level navigations from within p, such as visitors
+// following links, and on the frames of framing pages. Enforcement stops
+// when the connection to the page is dropped.
+func (n *navPolicy) enforce(p *rod.Page, screenID string) error {
+	// Subscribe before enabling interception, so that no paused request is
+	// missed and left hanging.
+	events := p.Event()
+	enable := proto.FetchEnable{
+		Patterns: []*proto.FetchRequestPattern{{
+			URLPattern:   "*",
+			ResourceType: proto.NetworkResourceTypeDocument,
+			RequestStage: proto.FetchRequestStageRequest,
+		}},
+	}
+	if err := enable.Call(p); err != nil {
+		return err
+	}
+	go func() {
+		// top is the page last allowed in the top-level frame.
+		var top *url.URL
+		for msg := range events {
+			var e proto.FetchRequestPaused
+			if msg.Load(&e) {
+				top = n.intercept(p, &e, screenID, top)
+			}
+		}
+	}()
+	return nil
+}
+
+// framesOnBehalf is true if top is one of the policy's framing pages, whose
+// frames must then be policed like the Screen's own navigations. Safe to call
+// on a nil navPolicy.
+func (n *navPolicy) framesOnBehalf(top *url.URL) bool {
+	if n == nil || top == nil {
+		return false
+	}
+	for _, f := range n.framing {
+		if strings.EqualFold(top.Scheme, f.Scheme) && strings.EqualFold(top.Host, f.Host) && strings.HasPrefix(top.Path, f.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin is true if u is served from the same origin as top.
+func sameOrigin(top *url.URL, u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && strings.EqualFold(parsed.Scheme, top.Scheme) && strings.EqualFold(parsed.Host, top.Host)
+}
+
+// intercept a paused document request, continuing it if the policy allows it
+// and blocking it otherwise. top is the page in the top-level frame, and the
+// one there afterwards is returned.
+func (n *navPolicy) intercept(p *rod.Page, e *proto.FetchRequestPaused, screenID string, top *url.URL) *url.URL {
+	u := e.Request.URL
+	topLevel := e.FrameID == p.FrameID
+	// Frames embedded in an allowed page are the page's business, unless the
+	// page is a layout or app framing them on the Screen's behalf. Those may
+	// frame their own server's pages, such as media and messages.
+	allowed := n.allows(u)
+	if !topLevel {
+		allowed = allowed || !n.framesOnBehalf(top) || sameOrigin(top, u)
+	}
+	if allowed {
+		if topLevel {
+			top, _ = url.Parse(u)
+		}
+		if err := (proto.FetchContinueRequest{RequestID: e.RequestID}).Call(p); err != nil {
+			logrus.WithError(err).WithField("screen", screenID).Debug("continuing request failed")
+		}
+		return top
+	}
+	source := "page"
+	if !topLevel {
+		source = "frame"
+	}
+	logrus.WithFields(logrus.Fields{
+		"screen": screenID,
+		"target": u,
+		"source": source,
+	}).Warn("navigation blocked")
+	if err := n.fulfillBlocked(e.RequestID, u).Call(p); err != nil {
+		logrus.WithError(err).WithField("screen", screenID).Warn("blocking navigation failed")
+	}
+	if topLevel {
+		// The blocked page takes its place.
+		return nil
+	}
+	return top
+}
+
+const blockedPage = `<!DOCTYPE html>
+<html><head><style>
+body,html {
+	background-color: #333;
+	color: #eee;
+	font-family: serif;
+}
+div#main-content {
+	margin-top: 25%%;
+	font-size: xx-large;
+}
+div#main-content p {
+	text-align: center;
+}
+p.subtle {
+	font-size: small;
+	color: #666;
+}
+</style><title>Not Allowed</title></head>
+<body>
+<div id="main-content">
+<p>Sorry, this screen can't show that.</p>
+<p class="subtle">%v</p>
+</div>
+</body></html>
+`
+
+// fulfillBlocked answers a blocked navigation to u, either by redirecting to
+// the configured blocked URL or with the built-in page.
+func (n *navPolicy) fulfillBlocked(id proto.FetchRequestID, u string) *proto.FetchFulfillRequest {
+	if n.blockedURL != "" {
+		sep := "?"
+		if strings.Contains(n.blockedURL, "?") {
+			sep = "&"
+		}
+		return &proto.FetchFulfillRequest{
+			RequestID:    id,
+			ResponseCode: http.StatusFound,
+			ResponseHeaders: []*proto.FetchHeaderEntry{{
+				Name:  "Location",
+				Value: n.blockedURL + sep + "url=" + url.QueryEscape(u),
+			}},
+		}
+	}
+	return &proto.FetchFulfillRequest{
+		RequestID:    id,
+		ResponseCode: http.StatusForbidden,
+		ResponseHeaders: []*proto.FetchHeaderEntry{{
+			Name:  "Content-Type",
+			Value: "text/html; charset=utf-8",
+		}},
+		Body: []byte(fmt.Sprintf(blockedPage, html.EscapeString(u))),
+	}
+}
+
+// checkAllowed returns ErrNotAllowed if the Screen's policy forbids u, and logs
+// the attempt.
+func (s *localScreen) checkAllowed(u string) error {
+	if s.policy.allows(u) {
+		return nil
+	}
+	logrus.WithFields(logrus.Fields{
+		"screen": s.id,
+		"target": u,
+		"source": "api",
+	}).Warn("navigation blocked")
+	return fmt.Errorf("%w: %v", ErrNotAllowed, u)
+}
@@ -0,0 +1,209 @@
+package pijector
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// logCapacity is the number of entries kept in each of a Screen's logs. Older
+// entries are dropped to make room for newer ones.
+const logCapacity = 100
+
+// LogEntry recorded from the page displayed on a Screen.
+type LogEntry struct {
+	Time time.Time `json:"time"`
+	// Level of a console message, like "log", "warning" or "error". For
+	// exceptions and failed requests, it is always "error".
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	// URL of the script which logged or threw, or of the request which failed.
+	URL string `json:"url,omitempty"`
+}
+
+// ScreenLogs are the most recent entries in each of a Screen's logs, oldest
+// first.
+type ScreenLogs struct {
+	Console    []LogEntry `json:"console"`
+	Exceptions []LogEntry `json:"exceptions"`
+	Network    []LogEntry `json:"network"`
+}
+
+// ErrorCounts summarizes the errors logged by pages displayed on a Screen,
+// including those no longer kept in its logs.
+type ErrorCounts struct {
+	Console    int `json:"console"`
+	Exceptions int `json:"exceptions"`
+	Network    int `json:"network"`
+}
+
+// PageLogger is implemented by Screens which keep logs of what happens in the
+// pages they display.
+type PageLogger interface {
+	// Logs of the Screen.
+	Logs() (ScreenLogs, error)
+}
+
+// logRing keeps the latest logCapacity entries.
+type logRing struct {
+	entries []LogEntry
+	next    int
+}
+
+func (r *logRing) add(e LogEntry) {
+	if len(r.entries) < logCapacity {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % logCapacity
+}
+
+// list the entries, oldest first.
+func (r *logRing) list() []LogEntry {
+	res := make([]LogEntry, 0, len(r.entries))
+	res = append(res, r.entries[r.next:]...)
+	return append(res, r.entries[:r.next]...)
+}
+
+// pageLogs of a local Screen, which outlive the pages they're recorded from.
+type pageLogs struct {
+	sync.Mutex // protects following members
+	console    logRing
+	exceptions logRing
+	network    logRing
+	counts     ErrorCounts
+	// requests in flight, by ID, so that failures can name their URL.
+	requests map[proto.NetworkRequestID]string
+}
+
+func newPageLogs() *pageLogs {
+	return &pageLogs{
+		requests: make(map[proto.NetworkRequestID]string),
+	}
+}
+
+// describe a value logged to the console.
+func describe(o *proto.RuntimeRemoteObject) string {
+	switch {
+	case o.Type == proto.RuntimeRemoteObjectTypeString:
+		return o.Value.Str()
+	case o.Type == proto.RuntimeRemoteObjectTypeUndefined:
+		return "undefined"
+	case o.UnserializableValue != "":
+		return string(o.UnserializableValue)
+	case o.Description != "":
+		return o.Description
+	}
+	return o.Value.JSON("", "")
+}
+
+// topURL of the script at the top of a stack trace.
+func topURL(st *proto.RuntimeStackTrace) string {
+	if st == nil || len(st.CallFrames) == 0 {
+		return ""
+	}
+	return st.CallFrames[0].URL
+}
+
+// watch p, recording its console messages, uncaught exceptions and failed
+// requests. Watching stops when the connection to the page is dropped.
+func (l *pageLogs) watch(p *rod.Page) {
+	l.Lock()
+	l.requests = make(map[proto.NetworkRequestID]string)
+	l.Unlock()
+	go p.EachEvent(func(e *proto.RuntimeConsoleAPICalled) {
+		var args []string
+		for _, arg := range e.Args {
+			args = append(args, describe(arg))
+		}
+		l.Lock()
+		defer l.Unlock()
+		l.console.add(LogEntry{
+			Time:    time.Now(),
+			Level:   string(e.Type),
+			Message: strings.Join(args, " "),
+			URL:     topURL(e.StackTrace),
+		})
+		if e.Type == proto.RuntimeConsoleAPICalledTypeError || e.Type == proto.RuntimeConsoleAPICalledTypeAssert {
+			l.counts.Console++
+		}
+	}, func(e *proto.RuntimeExceptionThrown) {
+		d := e.ExceptionDetails
+		msg := d.Text
+		if d.Exception != nil && d.Exception.Description != "" {
+			msg = d.Exception.Description
+		}
+		u := d.URL
+		if u == "" {
+			u = topURL(d.StackTrace)
+		}
+		l.Lock()
+		defer l.Unlock()
+		l.exceptions.add(LogEntry{
+			Time:    time.Now(),
+			Level:   "error",
+			Message: msg,
+			URL:     u,
+		})
+		l.counts.Exceptions++
+	}, func(e *proto.NetworkRequestWillBeSent) {
+		l.Lock()
+		defer l.Unlock()
+		l.requests[e.RequestID] = e.Request.URL
+	}, func(e *proto.NetworkLoadingFinished) {
+		l.Lock()
+		defer l.Unlock()
+		delete(l.requests, e.RequestID)
+	}, func(e *proto.NetworkLoadingFailed) {
+		l.Lock()
+		defer l.Unlock()
+		u := l.requests[e.RequestID]
+		delete(l.requests, e.RequestID)
+		if e.Canceled {
+			// Navigating away cancels whatever was loading, which is no error.
+			return
+		}
+		msg := e.ErrorText
+		if e.BlockedReason != "" {
+			msg += " (blocked: " + string(e.BlockedReason) + ")"
+		}
+		l.network.add(LogEntry{
+			Time:    time.Now(),
+			Level:   "error",
+			Message: msg,
+			URL:     u,
+		})
+		l.counts.Network++
+	})()
+}
+
+func (l *pageLogs) logs() ScreenLogs {
+	l.Lock()
+	defer l.Unlock()
+	return ScreenLogs{
+		Console:    l.console.list(),
+		Exceptions: l.exceptions.list(),
+		Network:    l.network.list(),
+	}
+}
+
+func (l *pageLogs) errorCounts() *ErrorCounts {
+	l.Lock()
+	defer l.Unlock()
+	counts := l.counts
+	return &counts
+}
+
+func (s *localScreen) Logs() (ScreenLogs, error) {
+	return s.logs.logs(), nil
+}
+
+func (s *remoteScreen) Logs() (logs ScreenLogs, err error) {
+	err = s.doJSON(http.MethodGet, "/logs", nil, &logs)
+	return
+}
@@ -0,0 +1,110 @@
+package pijector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "pijector"
+
+var (
+	showDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "show_duration_seconds",
+		Help:      "Time taken to show a URL on a screen, including waiting for it to load.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30},
+	}, []string{"screen"})
+	snapDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "snap_duration_seconds",
+		Help:      "Time taken to snap a screenshot of a screen.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"screen"})
+	lastShow = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_show_success_timestamp_seconds",
+		Help:      "When a screen last showed a URL successfully, as a Unix timestamp.",
+	}, []string{"screen"})
+	lastSnap = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_snap_success_timestamp_seconds",
+		Help:      "When a screen last snapped a screenshot successfully, as a Unix timestamp.",
+	}, []string{"screen"})
+	cdpConnected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cdp_connected",
+		Help:      "Whether the server is connected to a local screen's Chromium debugger.",
+	}, []string{"screen"})
+	cdpReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cdp_reconnects_total",
+		Help:      "Times the server has reconnected to a local screen's Chromium debugger after losing it.",
+	}, []string{"screen"})
+)
+
+// observeShow on the Screen with id, which started at start and ended with err.
+func observeShow(id string, start time.Time, err error) {
+	now := time.Now()
+	showDuration.WithLabelValues(id).Observe(now.Sub(start).Seconds())
+	if err == nil {
+		lastShow.WithLabelValues(id).Set(float64(now.UnixNano()) / 1e9)
+	}
+}
+
+// observeSnap of the Screen with id, which started at start and ended with err.
+func observeSnap(id string, start time.Time, err error) {
+	now := time.Now()
+	snapDuration.WithLabelValues(id).Observe(now.Sub(start).Seconds())
+	if err == nil {
+		lastSnap.WithLabelValues(id).Set(float64(now.UnixNano()) / 1e9)
+	}
+}
+
+var (
+	screenUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "screen", "up"),
+		"Whether the screen answered a status check.",
+		[]string{"screen", "name"}, nil)
+	screenInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "screen", "info"),
+		"What the screen is displaying, in its labels. Always 1.",
+		[]string{"screen", "name", "url", "title"}, nil)
+)
+
+// screenCollector checks on Screens whenever metrics are collected.
+type screenCollector struct {
+	screens []Screen
+}
+
+// NewScreenCollector of metrics about whether each of the screens is up, and
+// what it is displaying. Every screen's status is checked on each collection,
+// so remote screens are reported as the server sees them.
+func NewScreenCollector(screens []Screen) prometheus.Collector {
+	return &screenCollector{screens: screens}
+}
+
+func (c *screenCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- screenUpDesc
+	ch <- screenInfoDesc
+}
+
+func (c *screenCollector) Collect(ch chan<- prometheus.Metric) {
+	var wg sync.WaitGroup
+	for _, s := range c.screens {
+		wg.Add(1)
+		go func(s Screen) {
+			defer wg.Done()
+			up := 0.0
+			stat, err := s.Stat()
+			if err == nil {
+				up = 1
+				ch <- prometheus.MustNewConstMetric(screenInfoDesc, prometheus.GaugeValue, 1, s.ID(), s.Name(), stat.URL, stat.Title)
+			}
+			ch <- prometheus.MustNewConstMetric(screenUpDesc, prometheus.GaugeValue, up, s.ID(), s.Name())
+		}(s)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,246 @@
+package pijector
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/png" // Screenshots are PNGs.
+	"math/bits"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// ContentMonitor watches what a Screen displays for signs of trouble, by
+// comparing screenshots taken at an interval.
+type ContentMonitor struct {
+	// Interval between screenshots. Defaults to a minute.
+	Interval time.Duration
+	// StaleAfter is how long a page matching ExpectChange may look the same
+	// before the Screen is flagged as stale. Defaults to ten minutes.
+	StaleAfter time.Duration
+	// ExpectChange patterns, with the syntax of NavigationPolicy, matching the
+	// URLs of pages which should keep changing, like dashboards. Pages which
+	// don't match are never stale.
+	ExpectChange []string
+	// Reload the page when the Screen is flagged as stale or blank.
+	Reload bool
+}
+
+const (
+	defaultMonitorInterval = time.Minute
+	defaultStaleAfter      = 10 * time.Minute
+
+	// hashSize is the width and height of the grid compared between frames.
+	hashSize = 16
+	// blankTolerance is how far apart, out of 255, the darkest and lightest
+	// parts of a frame may be for it to count as a single solid color.
+	blankTolerance = 8
+	// sampleLimit is roughly how many pixels across a frame are sampled.
+	sampleLimit = 256
+)
+
+// frameHash is a difference hash of a frame. Each bit says whether a cell in a
+// grayscale thumbnail is brighter than its right neighbour, so it changes when
+// the content does, but not with noise in the screenshot.
+type frameHash [hashSize * hashSize / 64]uint64
+
+// distance between two hashes, as the number of bits which differ.
+func (h *frameHash) distance(o *frameHash) int {
+	d := 0
+	for i := range h {
+		d += bits.OnesCount64(h[i] ^ o[i])
+	}
+	return d
+}
+
+// luminance of a pixel, out of 255.
+func luminance(r, g, b uint32) float64 {
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
+
+// analyzeFrame hashes a frame, and reports whether it is a single solid color.
+func analyzeFrame(img image.Image) (*frameHash, bool) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	step := w / sampleLimit
+	if step < 1 {
+		step = 1
+	}
+	// The grid is one column wider than the hash, so that every cell in the hash
+	// has a right neighbour.
+	var sums, counts [hashSize][hashSize + 1]float64
+	lo, hi := 255.0, 0.0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			l := luminance(r, g, b)
+			if l < lo {
+				lo = l
+			}
+			if l > hi {
+				hi = l
+			}
+			row := (y - bounds.Min.Y) * hashSize / h
+			col := (x - bounds.Min.X) * (hashSize + 1) / w
+			sums[row][col] += l
+			counts[row][col]++
+		}
+	}
+	var hash frameHash
+	for row := 0; row < hashSize; row++ {
+		for col := 0; col < hashSize; col++ {
+			left, right := sums[row][col], sums[row][col+1]
+			if counts[row][col] > 0 {
+				left /= counts[row][col]
+			}
+			if counts[row][col+1] > 0 {
+				right /= counts[row][col+1]
+			}
+			if left > right {
+				bit := row*hashSize + col
+				hash[bit/64] |= 1 << (bit % 64)
+			}
+		}
+	}
+	return &hash, hi-lo <= blankTolerance
+}
+
+// contentMonitor is a compiled ContentMonitor, and what it has seen so far.
+type contentMonitor struct {
+	interval, staleAfter time.Duration
+	expectChange         []*regexp.Regexp
+	reload               bool
+
+	sync.Mutex // protects following members
+	url        string
+	hash       *frameHash
+	// since is when the page last changed, and reloaded when it was last
+	// reloaded because of a flag.
+	since    time.Time
+	reloaded time.Time
+	stale    bool
+	blank    bool
+}
+
+func newContentMonitor(m *ContentMonitor) (*contentMonitor, error) {
+	expect, err := compileURLPatterns(m.ExpectChange)
+	if err != nil {
+		return nil, err
+	}
+	cm := &contentMonitor{
+		interval:     m.Interval,
+		staleAfter:   m.StaleAfter,
+		expectChange: expect,
+		reload:       m.Reload,
+	}
+	if cm.interval <= 0 {
+		cm.interval = defaultMonitorInterval
+	}
+	if cm.staleAfter <= 0 {
+		cm.staleAfter = defaultStaleAfter
+	}
+	return cm, nil
+}
+
+// flags the Screen currently has raised. Safe to call on a nil contentMonitor.
+func (m *contentMonitor) flags() (stale, blank bool) {
+	if m == nil {
+		return false, false
+	}
+	m.Lock()
+	defer m.Unlock()
+	return m.stale, m.blank
+}
+
+// observe a frame of the page at u, returning the events it gives rise to, and
+// whether the page should be reloaded.
+func (m *contentMonitor) observe(u string, img image.Image, now time.Time) ([]ScreenEvent, bool) {
+	hash, blank := analyzeFrame(img)
+	m.Lock()
+	defer m.Unlock()
+	changed := u != m.url || m.hash == nil || hash.distance(m.hash) > 0
+	if changed {
+		m.url, m.hash, m.since = u, hash, now
+	}
+	// Only a change clears the flag, so a page which is still frozen after a
+	// reload stays stale.
+	stale := matchesAny(m.expectChange, u) && (now.Sub(m.since) >= m.staleAfter || (m.stale && !changed))
+	var events []ScreenEvent
+	raise := func(was, is bool, flag, message string) {
+		switch {
+		case is && !was:
+			events = append(events, ScreenEvent{Type: flag, Message: message, URL: u})
+		case was && !is:
+			events = append(events, ScreenEvent{Type: flag + "_cleared", URL: u})
+		}
+	}
+	raise(m.stale, stale, "stale", fmt.Sprintf("unchanged for %v", now.Sub(m.since).Round(time.Second)))
+	raise(m.blank, blank, "blank", "display is a single solid color")
+	// A page which stays stale is reloaded again after each further StaleAfter.
+	reload := m.reload && ((stale && now.Sub(m.reloaded) >= m.staleAfter) || (blank && !m.blank))
+	m.stale, m.blank = stale, blank
+	if reload {
+		m.reloaded = now
+	}
+	return events, reload
+}
+
+// monitorContent of the Screen until the process exits.
+func (s *localScreen) monitorContent() {
+	ticker := time.NewTicker(s.monitor.interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.checkContent(now)
+	}
+}
+
+// checkContent takes a screenshot of the Screen, and acts on what the monitor
+// makes of it.
+func (s *localScreen) checkContent(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	log := logrus.WithField("screen", s.id)
+	if err := s.attachIfNecessary(); err != nil {
+		log.WithError(err).Debug("content check skipped")
+		return
+	}
+	info, err := s.current.Info()
+	if err != nil {
+		log.WithError(err).Debug("content check failed")
+		s.checkConn(err)
+		return
+	}
+	data, err := s.current.Screenshot(false, &proto.PageCaptureScreenshot{
+		Format: proto.PageCaptureScreenshotFormatPng,
+	})
+	if err != nil {
+		log.WithError(err).Debug("content check failed")
+		s.checkConn(err)
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.WithError(err).Warn("content check couldn't decode screenshot")
+		return
+	}
+	events, reload := s.monitor.observe(info.URL, img, now)
+	for _, e := range events {
+		e.Screen = s.id
+		log.WithFields(logrus.Fields{
+			"event":  e.Type,
+			"target": e.URL,
+		}).Info("content check")
+		s.events.publish(e)
+	}
+	if reload {
+		log.WithField("target", info.URL).Info("reloading page")
+		if err := s.current.Reload(); err != nil {
+			log.WithError(err).Warn("reloading page failed")
+			s.checkConn(err)
+		}
+	}
+}
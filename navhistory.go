@@ -0,0 +1,118 @@
+package pijector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrNoSuchEntry is returned when asked to restore a navigation history entry
+// which doesn't exist.
+var ErrNoSuchEntry = errors.New("no such history entry")
+
+// navHistoryCapacity is how many URLs are remembered for each Screen.
+const navHistoryCapacity = 50
+
+// PlayRestore is a Screen going back to something it displayed before.
+const PlayRestore PlaySource = "restore"
+
+// HistoryEntry is a URL a Screen was told to show.
+type HistoryEntry struct {
+	URL    string     `json:"url"`
+	Time   time.Time  `json:"time"`
+	Source PlaySource `json:"source"`
+}
+
+// Navigator is implemented by Screens which remember what they showed, and can
+// go back to it.
+type Navigator interface {
+	// NavigationHistory of the Screen, most recent first, so that entry 0 is
+	// what it is displaying now.
+	NavigationHistory() ([]HistoryEntry, error)
+	// Back to what the Screen was displaying before its current URL. Going back
+	// twice undoes the first.
+	Back() error
+	// Restore entry n of the NavigationHistory.
+	Restore(n int) error
+}
+
+// remember u being shown because of source. This function assumes the lock is
+// held before calling.
+func (s *localScreen) remember(u string, source PlaySource) {
+	if len(s.history) > 0 && s.history[0].URL == u {
+		// Retries and reloads aren't worth an entry of their own.
+		return
+	}
+	entry := HistoryEntry{URL: u, Time: time.Now(), Source: source}
+	if len(s.history) < navHistoryCapacity {
+		s.history = append(s.history, HistoryEntry{})
+	}
+	copy(s.history[1:], s.history)
+	s.history[0] = entry
+}
+
+func (s *localScreen) NavigationHistory() ([]HistoryEntry, error) {
+	s.Lock()
+	defer s.Unlock()
+	history := make([]HistoryEntry, len(s.history))
+	copy(history, s.history)
+	return history, nil
+}
+
+func (s *localScreen) Back() error {
+	return s.Restore(1)
+}
+
+func (s *localScreen) Restore(n int) error {
+	s.Lock()
+	defer s.Unlock()
+	if n < 0 || n >= len(s.history) {
+		return fmt.Errorf("%w: %v", ErrNoSuchEntry, n)
+	}
+	u := s.history[n].URL
+	// Restoring is a Show like any other, so the navigation policy applies.
+	if err := s.checkAllowed(u); err != nil {
+		return err
+	}
+	s.cancelRevert()
+	return s.show(u, PlayRestore)
+}
+
+type navigationPayload struct {
+	Navigation []HistoryEntry `json:"navigation"`
+}
+
+func (s *remoteScreen) NavigationHistory() ([]HistoryEntry, error) {
+	var p navigationPayload
+	// Only the navigation is wanted, not the remote server's snapshots.
+	if err := s.doJSON(http.MethodGet, "/history?only=navigation", nil, &p); err != nil {
+		return nil, err
+	}
+	return p.Navigation, nil
+}
+
+// vetRestoreResponse maps the remote navigation policy refusing the entry to
+// ErrNotAllowed.
+func vetRestoreResponse(r *http.Response) error {
+	if r.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: %v", ErrNotAllowed, readError(r))
+	}
+	return vetResponse(r)
+}
+
+func (s *remoteScreen) Back() error {
+	err := s.doJSONVetted(http.MethodPost, "/history/back", nil, nil, vetRestoreResponse)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %v", ErrNoSuchEntry, 1)
+	}
+	return err
+}
+
+func (s *remoteScreen) Restore(n int) error {
+	err := s.doJSONVetted(http.MethodPost, fmt.Sprintf("/history/restore/%d", n), nil, nil, vetRestoreResponse)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %v", ErrNoSuchEntry, n)
+	}
+	return err
+}
@@ -0,0 +1,383 @@
+package pijector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNoSuchOverlay is returned when removing an overlay which doesn't exist.
+	ErrNoSuchOverlay = errors.New("no such overlay")
+	// ErrBadOverlay is returned when adding an overlay which can't be shown.
+	ErrBadOverlay = errors.New("bad overlay")
+)
+
+// OverlayStyle is what an Overlay looks like.
+type OverlayStyle string
+
+const (
+	// OverlayBanner is a strip across the top or bottom of the page.
+	OverlayBanner OverlayStyle = "banner"
+	// OverlayTicker is a strip across the top or bottom of the page, through
+	// which its text scrolls.
+	OverlayTicker OverlayStyle = "ticker"
+	// OverlayClock is the time, in a corner of the page.
+	OverlayClock OverlayStyle = "clock"
+	// OverlayCard is a box in the middle of the page, whose first line of text
+	// is a title, with an optional image below.
+	OverlayCard OverlayStyle = "card"
+)
+
+// overlayDefaults for each style: the positions it may take, the first being
+// the default, and its default colors.
+var overlayDefaults = map[OverlayStyle]struct {
+	positions         []string
+	color, background string
+}{
+	OverlayBanner: {[]string{"top", "bottom"}, "#fff", "#b00"},
+	OverlayTicker: {[]string{"bottom", "top"}, "#fff", "rgba(0, 0, 0, 0.8)"},
+	OverlayClock:  {[]string{"top-right", "top-left", "bottom-right", "bottom-left"}, "#fff", "rgba(0, 0, 0, 0.6)"},
+	OverlayCard:   {[]string{"center"}, "#fff", "rgba(0, 0, 0, 0.85)"},
+}
+
+// defaultTickerSpeed in pixels per second.
+const defaultTickerSpeed = 100
+
+// Overlay is a layer shown on top of every page displayed on a Screen, without
+// replacing the page.
+type Overlay struct {
+	ID    string       `json:"id,omitempty"`
+	Style OverlayStyle `json:"style"`
+	// Text of a banner, ticker or card. A clock shows it before the time.
+	Text string `json:"text,omitempty"`
+	// Image URL shown on a card.
+	Image string `json:"image,omitempty"`
+	// Position of the overlay: "top" or "bottom" for banners and tickers, a
+	// corner such as "top-right" for clocks, and "center" for cards.
+	Position string `json:"position,omitempty"`
+	// Color and Background are CSS colors.
+	Color      string `json:"color,omitempty"`
+	Background string `json:"background,omitempty"`
+	// Size is a CSS font size.
+	Size string `json:"size,omitempty"`
+	// Speed at which a ticker scrolls, in pixels per second.
+	Speed int `json:"speed,omitempty"`
+	// Expires is when the overlay is removed, if ever.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// check that the overlay can be shown, filling in its defaults.
+func (o *Overlay) check(now time.Time) error {
+	defaults, ok := overlayDefaults[o.Style]
+	if !ok {
+		return fmt.Errorf("%w: unknown style %q", ErrBadOverlay, o.Style)
+	}
+	if o.Text == "" && o.Style != OverlayClock {
+		return fmt.Errorf("%w: %v needs text", ErrBadOverlay, o.Style)
+	}
+	if o.Position == "" {
+		o.Position = defaults.positions[0]
+	}
+	valid := false
+	for _, p := range defaults.positions {
+		valid = valid || p == o.Position
+	}
+	if !valid {
+		return fmt.Errorf("%w: %v can't be at %q", ErrBadOverlay, o.Style, o.Position)
+	}
+	if o.Color == "" {
+		o.Color = defaults.color
+	}
+	if o.Background == "" {
+		o.Background = defaults.background
+	}
+	if o.Speed < 0 {
+		return fmt.Errorf("%w: negative speed", ErrBadOverlay)
+	}
+	if o.Style == OverlayTicker && o.Speed == 0 {
+		o.Speed = defaultTickerSpeed
+	}
+	if o.Expires != nil && !o.Expires.After(now) {
+		return fmt.Errorf("%w: already expired", ErrBadOverlay)
+	}
+	return nil
+}
+
+// Overlayer is implemented by Screens which can show overlays on top of the
+// pages they display.
+type Overlayer interface {
+	// Overlays shown on the Screen.
+	Overlays() ([]Overlay, error)
+	// AddOverlay to the Screen, returning it with its ID and defaults set. It
+	// replaces any overlay with the same ID. Overlays are shown immediately, and
+	// on every later navigation until they expire.
+	AddOverlay(o Overlay) (Overlay, error)
+	// RemoveOverlay by ID.
+	RemoveOverlay(id string) error
+}
+
+// overlayScript draws an overlay in a shadow root, so that the page's styles
+// can't reach it, attached to the document element so that it survives pages
+// replacing their body. It runs before the page's own scripts, so it waits
+// until there is a document to work with.
+const overlayScript = `(() => {
+	const overlay = %v;
+	const show = () => {
+		const id = 'pijector-overlay-' + overlay.id;
+		let host = document.getElementById(id);
+		if (host) {
+			host.remove();
+		}
+		host = document.createElement('div');
+		host.id = id;
+		host.style.cssText = 'all:initial;position:fixed;z-index:2147483646;pointer-events:none;';
+		const root = host.attachShadow({mode: 'closed'});
+		const style = document.createElement('style');
+		style.textContent = '@keyframes ticker { from { transform: translateX(0); } to { transform: translateX(-100%%); } }';
+		const box = document.createElement('div');
+		box.style.cssText = 'box-sizing:border-box;font-family:sans-serif;font-size:x-large;line-height:1.4;';
+		box.style.color = overlay.color;
+		box.style.background = overlay.background;
+		if (overlay.size) {
+			box.style.fontSize = overlay.size;
+		}
+		root.append(style, box);
+		if (overlay.style === 'clock') {
+			overlay.position.split('-').forEach((edge) => host.style[edge] = '1em');
+			box.style.padding = '.25em .5em';
+			box.style.borderRadius = '.25em';
+			const tick = () => {
+				if (!host.isConnected) {
+					return;
+				}
+				const now = new Date().toLocaleTimeString([], {hour: '2-digit', minute: '2-digit'});
+				box.textContent = overlay.text ? overlay.text + ' ' + now : now;
+				setTimeout(tick, 1000);
+			};
+			tick();
+		} else if (overlay.style === 'card') {
+			host.style.top = host.style.bottom = host.style.left = host.style.right = '0';
+			host.style.display = 'flex';
+			host.style.alignItems = 'center';
+			host.style.justifyContent = 'center';
+			box.style.padding = '.5em 1em';
+			box.style.borderRadius = '.25em';
+			box.style.textAlign = 'center';
+			overlay.text.split('\n').forEach((line, i) => {
+				const node = document.createElement('div');
+				if (!i) {
+					node.style.fontSize = '2.5em';
+					node.style.fontWeight = 'bold';
+				}
+				node.textContent = line;
+				box.appendChild(node);
+			});
+			if (overlay.image) {
+				const img = document.createElement('img');
+				img.src = overlay.image;
+				img.style.cssText = 'display:block;margin:.5em auto 0;max-height:40vh;image-rendering:pixelated;';
+				box.appendChild(img);
+			}
+		} else {
+			host.style.left = '0';
+			host.style.right = '0';
+			host.style[overlay.position] = '0';
+			box.style.padding = '.5em 1em';
+			if (overlay.style === 'ticker') {
+				box.style.overflow = 'hidden';
+				box.style.whiteSpace = 'nowrap';
+				const text = document.createElement('span');
+				text.style.cssText = 'display:inline-block;padding-left:100%%;animation:ticker linear infinite;';
+				text.textContent = overlay.text;
+				box.appendChild(text);
+				document.documentElement.appendChild(host);
+				text.style.animationDuration = (text.offsetWidth / overlay.speed) + 's';
+				return;
+			}
+			box.style.textAlign = 'center';
+			box.textContent = overlay.text;
+		}
+		document.documentElement.appendChild(host);
+	};
+	if (document.readyState === 'loading') {
+		document.addEventListener('DOMContentLoaded', show);
+	} else {
+		show();
+	}
+})()`
+
+// overlayRemoveScript takes an overlay off the current document.
+const overlayRemoveScript = `(id) => {
+	const host = document.getElementById('pijector-overlay-' + id);
+	if (host) {
+		host.remove();
+	}
+}`
+
+func (o *Overlay) scriptID() string {
+	return o.ID
+}
+
+func (o *Overlay) script() (string, error) {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(overlayScript, string(data)), nil
+}
+
+// overlays manages the overlays of a local Screen, their registration with its
+// current page, and their expiry.
+type overlays struct {
+	sync.Mutex // protects following members
+	shown      []Overlay
+	scripts    pageScripts
+	timers     map[string]*time.Timer
+}
+
+func newOverlays() *overlays {
+	return &overlays{
+		scripts: pageScripts{field: "overlay"},
+		timers:  make(map[string]*time.Timer),
+	}
+}
+
+func (ov *overlays) list() []Overlay {
+	ov.Lock()
+	defer ov.Unlock()
+	return append([]Overlay(nil), ov.shown...)
+}
+
+func (ov *overlays) add(o Overlay) (Overlay, error) {
+	if err := o.check(time.Now()); err != nil {
+		return o, err
+	}
+	if o.ID == "" {
+		o.ID = uuid.NewString()
+	}
+	ov.Lock()
+	defer ov.Unlock()
+	ov.removeLocked(o.ID)
+	ov.shown = append(ov.shown, o)
+	if o.Expires != nil {
+		id := o.ID
+		ov.timers[id] = time.AfterFunc(time.Until(*o.Expires), func() { ov.expire(id) })
+	}
+	if ov.scripts.attached() {
+		if err := ov.scripts.register(&o, true); err != nil {
+			// The overlay still shows from the next time a page is set up.
+			logrus.WithError(err).WithField("overlay", o.ID).Warn("registering overlay failed")
+		}
+	}
+	return o, nil
+}
+
+func (ov *overlays) remove(id string) error {
+	ov.Lock()
+	defer ov.Unlock()
+	if !ov.removeLocked(id) {
+		return fmt.Errorf("%w: %v", ErrNoSuchOverlay, id)
+	}
+	return nil
+}
+
+// expire the overlay with id, unless it has been replaced by one which hasn't
+// expired yet.
+func (ov *overlays) expire(id string) {
+	ov.Lock()
+	defer ov.Unlock()
+	for _, o := range ov.shown {
+		if o.ID == id && o.Expires != nil && !time.Now().Before(*o.Expires) {
+			ov.removeLocked(id)
+			return
+		}
+	}
+}
+
+// removeLocked takes the overlay with id off the page, returning false if there
+// is no such overlay. This function assumes the lock is held before calling.
+func (ov *overlays) removeLocked(id string) bool {
+	for i, o := range ov.shown {
+		if o.ID != id {
+			continue
+		}
+		if t := ov.timers[id]; t != nil {
+			t.Stop()
+			delete(ov.timers, id)
+		}
+		ov.scripts.unregister(id)
+		if ov.scripts.attached() {
+			if _, err := ov.scripts.page.Timeout(scriptTimeout).Eval(overlayRemoveScript, id); err != nil {
+				logrus.WithError(err).WithField("overlay", id).Debug("removing overlay from current document failed")
+			}
+		}
+		ov.shown = append(ov.shown[:i], ov.shown[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// apply all overlays to p, which has become the Screen's page.
+func (ov *overlays) apply(p *rod.Page) {
+	ov.Lock()
+	defer ov.Unlock()
+	scripts := make([]pageScript, 0, len(ov.shown))
+	for i := range ov.shown {
+		scripts = append(scripts, &ov.shown[i])
+	}
+	ov.scripts.apply(p, scripts)
+}
+
+// detach from the page, which is no longer usable.
+func (ov *overlays) detach() {
+	ov.Lock()
+	defer ov.Unlock()
+	ov.scripts.detach()
+}
+
+func (s *localScreen) Overlays() ([]Overlay, error) {
+	return s.overlay.list(), nil
+}
+
+func (s *localScreen) AddOverlay(o Overlay) (Overlay, error) {
+	s.Lock()
+	defer s.Unlock()
+	// Attaching applies existing overlays to the page, so only the new one needs
+	// registering.
+	if err := s.attachIfNecessary(); err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Debug("overlay will show once attached")
+	}
+	return s.overlay.add(o)
+}
+
+func (s *localScreen) RemoveOverlay(id string) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.overlay.remove(id)
+}
+
+func (s *remoteScreen) Overlays() (shown []Overlay, err error) {
+	err = s.doJSON(http.MethodGet, "/overlay", nil, &shown)
+	return
+}
+
+func (s *remoteScreen) AddOverlay(o Overlay) (added Overlay, err error) {
+	err = s.doJSON(http.MethodPost, "/overlay", &o, &added)
+	return
+}
+
+func (s *remoteScreen) RemoveOverlay(id string) error {
+	err := s.doJSON(http.MethodDelete, "/overlay/"+id, nil, nil)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %v", ErrNoSuchOverlay, id)
+	}
+	return err
+}
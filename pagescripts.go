@@ -0,0 +1,81 @@
+package pijector
+
+import (
+	"github.com/go-rod/rod"
+	"github.com/sirupsen/logrus"
+)
+
+// pageScript is run on every new document of a Screen's page, such as an
+// injection rule or an overlay.
+type pageScript interface {
+	scriptID() string
+	script() (string, error)
+}
+
+// pageScripts keeps track of the scripts registered with a Screen's current
+// page, so that they can be removed again, and registered with the next page
+// when it changes. It has no lock of its own; its owner's must be held when
+// calling its methods.
+type pageScripts struct {
+	// field logging the ID of a script, such as "rule" or "overlay".
+	field    string
+	page     *rod.Page
+	removers map[string]func() error
+}
+
+// attached is true if there is a page to register scripts with.
+func (ps *pageScripts) attached() bool {
+	return ps.page != nil
+}
+
+// apply scripts to p, which has become the Screen's page. The current document
+// is left alone, since it was loaded before p became current. A script which
+// can't be registered is skipped, so that it doesn't keep the rest off the
+// page.
+func (ps *pageScripts) apply(p *rod.Page, scripts []pageScript) {
+	ps.page = p
+	ps.removers = make(map[string]func() error)
+	for _, s := range scripts {
+		if err := ps.register(s, false); err != nil {
+			logrus.WithError(err).WithField(ps.field, s.scriptID()).Warn("registering script failed")
+		}
+	}
+}
+
+// detach from the page, which is no longer usable.
+func (ps *pageScripts) detach() {
+	ps.page = nil
+	ps.removers = nil
+}
+
+// register s with the page, to be evaluated on every new document, and on the
+// current one if now is set.
+func (ps *pageScripts) register(s pageScript, now bool) error {
+	js, err := s.script()
+	if err != nil {
+		return err
+	}
+	remove, err := ps.page.EvalOnNewDocument(js)
+	if err != nil {
+		return err
+	}
+	ps.removers[s.scriptID()] = remove
+	if now {
+		if _, err := ps.page.Timeout(scriptTimeout).Evaluate(rod.Eval(js)); err != nil {
+			logrus.WithError(err).WithField(ps.field, s.scriptID()).Debug("running script on current document failed")
+		}
+	}
+	return nil
+}
+
+// unregister the script with id, so that new documents don't evaluate it.
+// Whatever it already did to the current document stays until the next
+// navigation.
+func (ps *pageScripts) unregister(id string) {
+	if remove := ps.removers[id]; remove != nil {
+		if err := remove(); err != nil {
+			logrus.WithError(err).WithField(ps.field, id).Debug("removing script failed")
+		}
+		delete(ps.removers, id)
+	}
+}
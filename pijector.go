@@ -4,6 +4,7 @@ package pijector
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -22,6 +23,7 @@ import (
 	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -40,6 +42,25 @@ func localScreenID(addr string) string {
 type ScreenStatus struct {
 	Title string `json:"title,omitempty"`
 	URL   string `json:"url"`
+	// Remaining seconds before a time-limited Show reverts. Zero when the
+	// current display is not time-limited.
+	Remaining int `json:"remaining,omitempty"`
+	// TimeZone, Locale and ColorScheme in effect for the current page.
+	TimeZone    string `json:"time_zone,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+	ColorScheme string `json:"color_scheme,omitempty"`
+	// Geolocation reported to pages, if it's emulated.
+	Geolocation *Geolocation `json:"geolocation,omitempty"`
+	// Errors logged by pages displayed on the Screen.
+	Errors *ErrorCounts `json:"errors,omitempty"`
+	// HTTPStatus of the last page the Screen was told to show, or the NetError
+	// which stopped it loading.
+	HTTPStatus int    `json:"http_status,omitempty"`
+	NetError   string `json:"net_error,omitempty"`
+	// Stale is set when a page expected to keep changing hasn't for a while, and
+	// Blank when the display is a single solid color.
+	Stale bool `json:"stale,omitempty"`
+	Blank bool `json:"blank,omitempty"`
 }
 
 // Screen represents a single Pijector display.
@@ -51,6 +72,9 @@ type Screen interface {
 	Name() string
 	// Show a url on the Screen.
 	Show(u string) error
+	// ShowFor shows a url on the Screen for the duration d, after which the
+	// Screen reverts to its previous display, or its default if there was none.
+	ShowFor(u string, d time.Duration) error
 	// Snap a screenshot of the Screen's current display.
 	Snap() (io.ReadCloser, error)
 	// Stat of the Screen.
@@ -61,36 +85,153 @@ type Screen interface {
 // Protocol.
 type localScreen struct {
 	addr, id, name string
-
-	sync.Mutex // protects following members
-	browser    *rod.Browser
-	current    *rod.Page
+	defaultURL     string
+
+	idle    *idleReset
+	policy  *navPolicy
+	popups  PopupPolicy
+	inject  *injections
+	overlay *overlays
+	logs    *pageLogs
+	retry   *LoadRetry
+	monitor *contentMonitor
+	events  *eventHub
+	plays   *PlayLog
+
+	sync.Mutex  // protects following members
+	browser     *rod.Browser
+	current     *rod.Page
+	disconnect  context.CancelFunc
+	unwatch     context.CancelFunc
+	revert      *pendingRevert
+	retrying    *pendingRetry
+	advance     *autoAdvance
+	emulation   Emulation
+	unrotate    func() error
+	colorScheme string
+	scheduling  bool
+	// connected is set once the Screen has first been attached, so that attaching
+	// again counts as a reconnect.
+	connected bool
+	// history of URLs shown, most recent first.
+	history []HistoryEntry
+	// loadStatus and loadError of the last page shown.
+	loadStatus int
+	loadError  string
 }
 
+var errNoPages = errors.New("browser has no pages")
+
+const (
+	// showLoadTimeout bounds how long Show waits for a page to finish loading.
+	showLoadTimeout = 30 * time.Second
+	// scriptTimeout bounds the scripts Pijector runs in pages for its own
+	// purposes, so that a wedged page can't hold anything up indefinitely.
+	scriptTimeout = 2 * time.Second
+)
+
 // attachIfNecessary connects to the chromium debugger lazily, when needed. This
 // allows the Pijector to be initialized before the Screen is actually available.
+// Once attached, the connection is reused until a call on it fails.
 // This function assumes the lock is held before calling.
 func (s *localScreen) attachIfNecessary() error {
+	if s.current != nil {
+		return nil
+	}
 	u, err := launcher.ResolveURL(s.addr)
 	if err != nil {
 		return err
 	}
-	browser := rod.New().ControlURL(u).DefaultDevice(devices.Clear)
+	ctx, cancel := context.WithCancel(context.Background())
+	browser := rod.New().Context(ctx).ControlURL(u).DefaultDevice(devices.Clear)
 	if err := browser.Connect(); err != nil {
+		cancel()
 		return err
 	}
 	pages, err := browser.Pages()
 	if err != nil {
+		cancel()
 		return err
 	}
+	if len(pages) < 1 {
+		cancel()
+		return errNoPages
+	}
 	if _, err := pages[0].Activate(); err != nil {
+		cancel()
 		return err
 	}
 	s.browser = browser
 	s.current = pages[0]
+	s.disconnect = cancel
+	s.setupPage(s.current)
+	if s.connected {
+		cdpReconnects.WithLabelValues(s.id).Inc()
+	}
+	s.connected = true
+	cdpConnected.WithLabelValues(s.id).Set(1)
 	return nil
 }
 
+// setupPage starts the per-page machinery which lives as long as p is the
+// current page, replacing that of any previous page. This function assumes the
+// lock is held before calling.
+func (s *localScreen) setupPage(p *rod.Page) {
+	if s.unwatch != nil {
+		s.unwatch()
+	}
+	ctx, cancel := context.WithCancel(p.GetContext())
+	s.unwatch = cancel
+	p = p.Context(ctx)
+	s.logs.watch(p)
+	s.plays.watch(p, s.id, s.Name())
+	s.dismissDialogs(p)
+	s.handlePopups(s.browser, p)
+	s.inject.apply(p)
+	s.overlay.apply(p)
+	s.unrotate = nil
+	if err := s.applyEmulation(p); err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Warn("emulation failed")
+	}
+	if s.idle != nil {
+		if err := s.idle.watch(p); err != nil {
+			logrus.WithError(err).WithField("screen", s.id).Warn("idle reset unavailable")
+		}
+	}
+	if s.policy != nil {
+		if err := s.policy.enforce(p, s.id); err != nil {
+			logrus.WithError(err).WithField("screen", s.id).Warn("navigation policy unenforceable")
+		}
+	}
+}
+
+// detach from the browser, so that the next call reconnects. This also stops
+// anything started by setupPage. This function assumes the lock is held before
+// calling.
+func (s *localScreen) detach() {
+	if s.disconnect != nil {
+		s.disconnect()
+	}
+	s.browser = nil
+	s.current = nil
+	s.disconnect = nil
+	s.unwatch = nil
+	s.inject.detach()
+	s.overlay.detach()
+	cdpConnected.WithLabelValues(s.id).Set(0)
+}
+
+// checkConn detaches from the browser when err is anything but a failure to
+// navigate, since the connection or page may be gone. The next call will then
+// reconnect. Returns err. This function assumes the lock is held before calling.
+func (s *localScreen) checkConn(err error) error {
+	var navErr *rod.ErrNavigation
+	if err != nil && !errors.As(err, &navErr) {
+		s.detach()
+	}
+	return err
+}
+
 func (s *localScreen) ID() string {
 	return s.id
 }
@@ -102,22 +243,156 @@ func (s *localScreen) Name() string {
 	return s.name
 }
 
-func (s *localScreen) Show(u string) error {
+func (s *localScreen) Show(u string) (err error) {
+	if err := s.checkAllowed(u); err != nil {
+		return err
+	}
+	start := time.Now()
+	defer func() { observeShow(s.id, start, err) }()
 	s.Lock()
 	defer s.Unlock()
+	s.cancelRevert()
+	return s.show(u, PlayManual)
+}
+
+// show navigates the current page to u because of source, and waits for it to
+// load. This function assumes the lock is held before calling.
+func (s *localScreen) show(u string, source PlaySource) error {
+	s.cancelRetry()
+	err := s.load(u, source)
+	s.retryIfFailed(u, err, 0)
+	return err
+}
+
+// load u on the current page because of source, and wait for it to finish
+// loading. Returns a *LoadError if the page answers with an HTTP error, or
+// doesn't answer at all. This function assumes the lock is held before calling.
+func (s *localScreen) load(u string, source PlaySource) error {
+	s.plays.loading(s.id, s.Name(), u, source)
+	s.remember(u, source)
+	landed, err := s.navigate(u)
+	s.plays.loaded(s.id, landed, err)
+	return err
+}
+
+// navigate to u, returning the URL the page landed on after any redirects. This
+// function assumes the lock is held before calling.
+func (s *localScreen) navigate(u string) (string, error) {
 	if err := s.attachIfNecessary(); err != nil {
-		return err
+		return "", err
+	}
+	if s.idle != nil {
+		s.idle.disarm()
 	}
-	var loadEvent proto.PageLoadEventFired
+	// Whatever deck was advancing is about to go away.
+	s.cancelAdvance()
+	s.loadStatus, s.loadError = 0, ""
+	// Subscribe before navigating, so that a fast load isn't missed. The wait is
+	// bounded, since a page may never finish loading.
+	ctx, cancel := context.WithTimeout(s.current.GetContext(), showLoadTimeout)
+	defer cancel()
+	var status int
+	var landed string
+	frame := s.current.FrameID
+	wait := s.current.Context(ctx).EachEvent(func(e *proto.PageLoadEventFired) bool {
+		return true
+	}, func(e *proto.NetworkResponseReceived) {
+		if e.Type == proto.NetworkResourceTypeDocument && e.FrameID == frame {
+			status = e.Response.Status
+		}
+	}, func(e *proto.PageFrameNavigated) {
+		if e.Frame.ID == frame {
+			landed = e.Frame.URL
+		}
+	})
 	if err := s.current.Navigate(u); err != nil {
+		var navErr *rod.ErrNavigation
+		if errors.As(err, &navErr) {
+			s.loadError = navErr.Reason
+			return "", &LoadError{URL: u, NetError: navErr.Reason}
+		}
+		return "", s.checkConn(err)
+	}
+	wait()
+	if ctx.Err() != nil {
+		logrus.WithFields(logrus.Fields{
+			"target": u,
+			"screen": s.id,
+		}).Warn("gave up waiting for page load")
+	}
+	s.loadStatus = status
+	if status >= http.StatusBadRequest {
+		return landed, &LoadError{URL: u, Status: status}
+	}
+	return landed, nil
+}
+
+func (s *localScreen) ShowFor(u string, d time.Duration) (err error) {
+	if d <= 0 {
+		return fmt.Errorf("%w: %v", errInvalidDuration, d)
+	}
+	if err := s.checkAllowed(u); err != nil {
 		return err
 	}
-	s.current.WaitEvent(&loadEvent)()
-	return nil
+	start := time.Now()
+	defer func() { observeShow(s.id, start, err) }()
+	s.Lock()
+	defer s.Unlock()
+	if err := s.attachIfNecessary(); err != nil {
+		return err
+	}
+	previous := s.defaultURL
+	if s.revert != nil {
+		// Stacked time-limited Shows revert to whatever was up before the first.
+		previous = s.revert.target
+	} else if info, err := s.current.Info(); err == nil && isRevertible(info.URL) {
+		previous = info.URL
+	}
+	s.cancelRevert()
+	err = s.show(u, PlayManual)
+	if err != nil && !errors.Is(err, ErrLoadFailed) {
+		return err
+	}
+	// A page which failed to load may still be retried, and must revert either
+	// way.
+	s.revert = newPendingRevert(previous, d, s.revertTo)
+	return err
 }
 
-func (s *localScreen) Snap() (io.ReadCloser, error) {
+// revertTo is called when a time-limited Show expires.
+func (s *localScreen) revertTo(r *pendingRevert) {
 	s.Lock()
+	defer s.Unlock()
+	if s.revert != r {
+		// Superseded by another Show while the timer was firing.
+		return
+	}
+	s.revert = nil
+	if r.target == "" {
+		return
+	}
+	if err := s.show(r.target, PlayRevert); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"target": r.target,
+			"screen": s.id,
+		}).Warn("revert failed")
+	}
+}
+
+// cancelRevert stops any pending revert. This function assumes the lock is held
+// before calling.
+func (s *localScreen) cancelRevert() {
+	if s.revert != nil {
+		s.revert.stop()
+		s.revert = nil
+	}
+}
+
+func (s *localScreen) Snap() (rc io.ReadCloser, err error) {
+	start := time.Now()
+	defer func() { observeSnap(s.id, start, err) }()
+	s.Lock()
+	defer s.Unlock()
 	if err := s.attachIfNecessary(); err != nil {
 		return nil, err
 	}
@@ -125,9 +400,8 @@ func (s *localScreen) Snap() (io.ReadCloser, error) {
 		Format: proto.PageCaptureScreenshotFormatPng,
 	}
 	data, err := s.current.Screenshot(false, ssReq)
-	s.Unlock()
 	if err != nil {
-		return nil, err
+		return nil, s.checkConn(err)
 	}
 	return ioutil.NopCloser(bytes.NewReader(data)), nil
 }
@@ -142,21 +416,167 @@ func (s *localScreen) Stat() (ScreenStatus, error) {
 	}
 	info, err := s.current.Info()
 	if err != nil {
-		return stat, err
+		return stat, s.checkConn(err)
 	}
 	stat.Title = info.Title
 	stat.URL = info.URL
+	stat.Remaining = s.revert.remaining()
+	s.statEnvironment(&stat)
+	stat.Errors = s.logs.errorCounts()
+	stat.HTTPStatus = s.loadStatus
+	stat.NetError = s.loadError
+	stat.Stale, stat.Blank = s.monitor.flags()
 	return stat, nil
 }
 
+type localInitOpt struct {
+	DefaultURL    string
+	IdleHomeURL   string
+	IdleTimeout   time.Duration
+	IdleCountdown time.Duration
+	Policy        *NavigationPolicy
+	Popups        PopupPolicy
+	Injections    []InjectionRule
+	Emulation     Emulation
+	Retry         *LoadRetry
+	Monitor       *ContentMonitor
+	Plays         *PlayLog
+}
+
+// LocalOption configures a local Screen.
+type LocalOption func(*localInitOpt)
+
+// WithDefaultURL to which the Screen reverts after a time-limited Show when
+// there is no previous display to return to.
+func WithDefaultURL(u string) LocalOption {
+	return func(o *localInitOpt) {
+		o.DefaultURL = u
+	}
+}
+
+// WithIdleReset returns the Screen to home after timeout passes without any
+// visitor interacting with the page. If countdown is positive, an overlay warns
+// visitors for that long before the Screen is reset. If home is empty, the
+// Screen's default URL is used.
+func WithIdleReset(home string, timeout, countdown time.Duration) LocalOption {
+	return func(o *localInitOpt) {
+		o.IdleHomeURL = home
+		o.IdleTimeout = timeout
+		o.IdleCountdown = countdown
+	}
+}
+
+// WithNavigationPolicy restricts the URLs the Screen may display, whether asked
+// to through Show or by following links within a page.
+func WithNavigationPolicy(p NavigationPolicy) LocalOption {
+	return func(o *localInitOpt) {
+		o.Policy = &p
+	}
+}
+
+// WithPopupPolicy decides what happens to windows opened by the Screen's page.
+// By default, they are closed.
+func WithPopupPolicy(p PopupPolicy) LocalOption {
+	return func(o *localInitOpt) {
+		o.Popups = p
+	}
+}
+
+// WithInjectionRules to apply to pages displayed on the Screen. More may be
+// added at runtime through the Injector interface.
+func WithInjectionRules(rules ...InjectionRule) LocalOption {
+	return func(o *localInitOpt) {
+		o.Injections = append(o.Injections, rules...)
+	}
+}
+
+// WithEmulation of display characteristics on the Screen. It is reapplied
+// whenever Pijector reconnects to the Screen.
+func WithEmulation(e Emulation) LocalOption {
+	return func(o *localInitOpt) {
+		o.Emulation = e
+	}
+}
+
+// WithLoadRetry retries pages which fail to load, and falls back to another
+// URL if they keep failing.
+func WithLoadRetry(r LoadRetry) LocalOption {
+	return func(o *localInitOpt) {
+		o.Retry = &r
+	}
+}
+
+// WithContentMonitor flags the Screen as stale or blank based on screenshots
+// taken at an interval.
+func WithContentMonitor(m ContentMonitor) LocalOption {
+	return func(o *localInitOpt) {
+		o.Monitor = &m
+	}
+}
+
+// WithPlayLog records everything the Screen displays in pl, for proof of play
+// reporting.
+func WithPlayLog(pl *PlayLog) LocalOption {
+	return func(o *localInitOpt) {
+		o.Plays = pl
+	}
+}
+
 // AttachLocal attaches a local Chromium instance via CDP at the provided addr,
 // and identifies it in Pijector with the provided human-friendly name.
-func AttachLocal(name, addr string) (Screen, error) {
-	return &localScreen{
-		addr: addr,
-		id:   localScreenID(addr),
-		name: name,
-	}, nil
+func AttachLocal(name, addr string, opts ...LocalOption) (Screen, error) {
+	o := &localInitOpt{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	inject, err := newInjections(o.Injections)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Emulation.validate(); err != nil {
+		return nil, err
+	}
+	s := &localScreen{
+		addr:       addr,
+		id:         localScreenID(addr),
+		name:       name,
+		defaultURL: o.DefaultURL,
+		popups:     o.Popups,
+		inject:     inject,
+		overlay:    newOverlays(),
+		logs:       newPageLogs(),
+		retry:      o.Retry,
+		events:     newEventHub(),
+		plays:      o.Plays,
+		emulation:  o.Emulation,
+	}
+	s.followColorSchedule()
+	if o.IdleTimeout > 0 {
+		home := o.IdleHomeURL
+		if home == "" {
+			home = o.DefaultURL
+		}
+		if home == "" {
+			return nil, errNoIdleHome
+		}
+		s.idle = newIdleReset(home, o.IdleTimeout, o.IdleCountdown, s.goHome)
+	}
+	if o.Policy != nil {
+		policy, err := newNavPolicy(o.Policy)
+		if err != nil {
+			return nil, err
+		}
+		s.policy = policy
+	}
+	if o.Monitor != nil {
+		monitor, err := newContentMonitor(o.Monitor)
+		if err != nil {
+			return nil, err
+		}
+		s.monitor = monitor
+		go s.monitorContent()
+	}
+	return s, nil
 }
 
 // remoteScreen uses the Pijector API to control a Screen attached locally to
@@ -177,16 +597,86 @@ func (s *remoteScreen) Name() string {
 	return s.name
 }
 
-var errHTTPFailure = errors.New("http request failed")
+var (
+	errHTTPFailure = errors.New("http request failed")
+	errNotFound    = fmt.Errorf("%w: not found", errHTTPFailure)
+)
+
+// maxErrorBody is as much of a failed response as is read for its message.
+const maxErrorBody = 4 << 10
+
+// readError from the body of a failed response, as written by the API.
+func readError(r *http.Response) string {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxErrorBody))
+	if err != nil {
+		return r.Status
+	}
+	return strings.TrimSpace(string(data))
+}
 
 func vetResponse(r *http.Response) error {
+	if r.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
 	if int(r.StatusCode/100) != 2 {
 		return fmt.Errorf("%w: %v", errHTTPFailure, r.Status)
 	}
 	return nil
 }
 
-func (s *remoteScreen) Show(u string) error {
+// doJSON makes a request to path under the remote Screen's API URL. If in is
+// not nil, it is sent as a JSON body. If out is not nil, the JSON response is
+// decoded into it.
+func (s *remoteScreen) doJSON(method, path string, in, out interface{}) error {
+	return s.doJSONVetted(method, path, in, out, vetResponse)
+}
+
+// doJSONVetted is doJSON, checking the response with vet.
+func (s *remoteScreen) doJSONVetted(method, path string, in, out interface{}, vet func(*http.Response) error) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.url+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.password != "" {
+		req.SetBasicAuth("pijector", s.password)
+	}
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := vet(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// vetShowResponse is vetResponse for Show requests, which recognizes a remote
+// navigation policy refusing the target.
+func vetShowResponse(r *http.Response, u string) error {
+	if r.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: %v", ErrNotAllowed, u)
+	}
+	return vetResponse(r)
+}
+
+func (s *remoteScreen) Show(u string) (err error) {
+	start := time.Now()
+	defer func() { observeShow(s.id, start, err) }()
 	reqURL := fmt.Sprintf("%v/show?target=%v", s.url, url.QueryEscape(u))
 	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
 	if err != nil {
@@ -196,10 +686,32 @@ func (s *remoteScreen) Show(u string) error {
 	if err != nil {
 		return err
 	}
-	return vetResponse(resp)
+	defer resp.Body.Close()
+	return vetShowResponse(resp, u)
+}
+
+func (s *remoteScreen) ShowFor(u string, d time.Duration) (err error) {
+	if d <= 0 {
+		return fmt.Errorf("%w: %v", errInvalidDuration, d)
+	}
+	start := time.Now()
+	defer func() { observeShow(s.id, start, err) }()
+	reqURL := fmt.Sprintf("%v/show?target=%v&duration=%v", s.url, url.QueryEscape(u), url.QueryEscape(d.String()))
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return vetShowResponse(resp, u)
 }
 
-func (s *remoteScreen) Snap() (io.ReadCloser, error) {
+func (s *remoteScreen) Snap() (rc io.ReadCloser, err error) {
+	start := time.Now()
+	defer func() { observeSnap(s.id, start, err) }()
 	reqURL := fmt.Sprintf("%v/snap", s.url)
 	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
 	if err != nil {
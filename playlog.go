@@ -0,0 +1,378 @@
+package pijector
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// PlaySource is what made a Screen display something.
+type PlaySource string
+
+const (
+	// PlayManual is a Show through the API.
+	PlayManual PlaySource = "manual"
+	// PlayRevert is the end of a time-limited Show.
+	PlayRevert PlaySource = "revert"
+	// PlayIdle is an idle reset.
+	PlayIdle PlaySource = "idle"
+	// PlayRetry is another attempt at a page which failed to load.
+	PlayRetry PlaySource = "retry"
+	// PlayFallback is the fallback for a page which kept failing to load.
+	PlayFallback PlaySource = "fallback"
+	// PlayPage is the page navigating by itself, or a visitor following a link.
+	PlayPage PlaySource = "page"
+)
+
+// PlayRecord is a stretch of time during which a Screen displayed a URL.
+type PlayRecord struct {
+	Screen     string     `json:"screen"`
+	ScreenName string     `json:"screen_name,omitempty"`
+	URL        string     `json:"url"`
+	Source     PlaySource `json:"source"`
+	Start      time.Time  `json:"start"`
+	// End of the play, which is nil while it's still on the Screen.
+	End *time.Time `json:"end,omitempty"`
+	// Loaded is set if the URL loaded successfully. Otherwise HTTPStatus or
+	// Error say why it didn't.
+	Loaded     bool   `json:"loaded"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// seconds of the play which fall between from and to, counting a play which is
+// still going as ending at now.
+func (r *PlayRecord) seconds(from, to, now time.Time) float64 {
+	start, end := r.Start, now
+	if r.End != nil {
+		end = *r.End
+	}
+	if !from.IsZero() && start.Before(from) {
+		start = from
+	}
+	if !to.IsZero() && end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Seconds()
+}
+
+// playState of one Screen.
+type playState struct {
+	current *PlayRecord
+	// loading is set while the Screen is loading a URL it was told to show, so
+	// that the navigation isn't also counted as the page navigating itself.
+	loading bool
+	// landed is the URL the current play ended up at, after any redirects.
+	landed string
+}
+
+// PlayLog records what every local Screen displays, and for how long, as JSON
+// lines appended to a file. Each play is written when it starts, so that it
+// isn't lost if the process dies, and again when it ends; the later line
+// supersedes the earlier.
+type PlayLog struct {
+	path string
+
+	sync.Mutex // protects following members
+	f          *os.File
+	screens    map[string]*playState
+}
+
+// OpenPlayLog appending to the file at path.
+func OpenPlayLog(path string) (*PlayLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &PlayLog{
+		path:    path,
+		f:       f,
+		screens: make(map[string]*playState),
+	}, nil
+}
+
+func (pl *PlayLog) stateLocked(screen string) *playState {
+	st := pl.screens[screen]
+	if st == nil {
+		st = &playState{}
+		pl.screens[screen] = st
+	}
+	return st
+}
+
+// writeLocked rec to the file. This function assumes the lock is held before
+// calling.
+func (pl *PlayLog) writeLocked(rec *PlayRecord) {
+	data, err := json.Marshal(rec)
+	if err == nil {
+		_, err = pl.f.Write(append(data, '\n'))
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("screen", rec.Screen).Error("recording play failed")
+	}
+}
+
+// endLocked the current play of st at now, and write it out. This function
+// assumes the lock is held before calling.
+func (pl *PlayLog) endLocked(st *playState, now time.Time) {
+	if st.current == nil {
+		return
+	}
+	rec := st.current
+	rec.End = &now
+	st.current = nil
+	pl.writeLocked(rec)
+}
+
+// beginLocked a new play on a Screen, writing it out unless it's still loading,
+// in which case it's written once it has loaded. This function assumes the
+// lock is held before calling.
+func (pl *PlayLog) beginLocked(st *playState, rec *PlayRecord) {
+	pl.endLocked(st, rec.Start)
+	st.current = rec
+	st.landed = rec.URL
+	if !st.loading {
+		pl.writeLocked(rec)
+	}
+}
+
+// loading u on the Screen, because of source. Safe to call on a nil PlayLog.
+func (pl *PlayLog) loading(screen, name, u string, source PlaySource) {
+	if pl == nil {
+		return
+	}
+	pl.Lock()
+	defer pl.Unlock()
+	st := pl.stateLocked(screen)
+	st.loading = true
+	pl.beginLocked(st, &PlayRecord{
+		Screen:     screen,
+		ScreenName: name,
+		URL:        u,
+		Source:     source,
+		Start:      time.Now(),
+	})
+}
+
+// loaded records how loading the current play went, and that it landed on
+// landed. Safe to call on a nil PlayLog.
+func (pl *PlayLog) loaded(screen, landed string, err error) {
+	if pl == nil {
+		return
+	}
+	pl.Lock()
+	defer pl.Unlock()
+	st := pl.stateLocked(screen)
+	st.loading = false
+	if st.current == nil {
+		return
+	}
+	if landed != "" {
+		st.landed = landed
+	}
+	rec := st.current
+	rec.Loaded = err == nil
+	if err != nil {
+		rec.Error = err.Error()
+		if le, ok := err.(*LoadError); ok {
+			rec.HTTPStatus = le.Status
+		}
+	}
+	pl.writeLocked(rec)
+}
+
+// navigated records the page on a Screen navigating to u by itself. Safe to
+// call on a nil PlayLog.
+func (pl *PlayLog) navigated(screen, name, u string) {
+	if pl == nil {
+		return
+	}
+	pl.Lock()
+	defer pl.Unlock()
+	// Chromium's error page stands in for a play which failed to load, rather
+	// than being a play of its own.
+	if strings.HasPrefix(u, "chrome-error:") {
+		return
+	}
+	st := pl.stateLocked(screen)
+	if st.loading || (st.current != nil && (u == st.current.URL || u == st.landed)) {
+		return
+	}
+	pl.beginLocked(st, &PlayRecord{
+		Screen:     screen,
+		ScreenName: name,
+		URL:        u,
+		Source:     PlayPage,
+		Start:      time.Now(),
+		Loaded:     true,
+	})
+}
+
+// watch p for navigations it makes by itself. Safe to call on a nil PlayLog.
+func (pl *PlayLog) watch(p *rod.Page, screen, name string) {
+	if pl == nil {
+		return
+	}
+	go p.EachEvent(func(e *proto.PageFrameNavigated) {
+		if e.Frame.ParentID == "" {
+			pl.navigated(screen, name, e.Frame.URL)
+		}
+	})()
+}
+
+// playKey identifies a play, by its Screen and when it started.
+type playKey struct {
+	screen string
+	start  int64
+}
+
+func (r *PlayRecord) key() playKey {
+	return playKey{r.Screen, r.Start.UnixNano()}
+}
+
+// Records of plays overlapping from and to, including those still going. A
+// zero from or to leaves that end of the range open.
+func (pl *PlayLog) Records(from, to time.Time) ([]PlayRecord, error) {
+	// Copy the plays still going, so that the file can be read without holding
+	// up Screens which are recording plays.
+	pl.Lock()
+	var current []PlayRecord
+	for _, st := range pl.screens {
+		if st.current != nil {
+			current = append(current, *st.current)
+		}
+	}
+	pl.Unlock()
+	f, err := os.Open(pl.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var all []PlayRecord
+	seen := make(map[playKey]int)
+	add := func(r PlayRecord) {
+		if i, ok := seen[r.key()]; ok {
+			all[i] = r
+			return
+		}
+		seen[r.key()] = len(all)
+		all = append(all, r)
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r PlayRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			logrus.WithError(err).WithField("path", pl.path).Warn("skipping unreadable play record")
+			continue
+		}
+		add(r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	going := make(map[playKey]bool)
+	for _, r := range current {
+		// A play which ended after it was copied is already in the file.
+		if i, ok := seen[r.key()]; ok && all[i].End != nil {
+			continue
+		}
+		going[r.key()] = true
+		add(r)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Start.Before(all[j].Start) })
+	// Plays which were never ended, because the process died, end when the next
+	// play on their Screen starts, or else count for nothing.
+	open := make(map[string]*PlayRecord)
+	for i := range all {
+		r := &all[i]
+		if prev := open[r.Screen]; prev != nil {
+			end := r.Start
+			prev.End = &end
+			delete(open, r.Screen)
+		}
+		if r.End == nil && !going[r.key()] {
+			open[r.Screen] = r
+		}
+	}
+	for _, r := range open {
+		end := r.Start
+		r.End = &end
+	}
+	var recs []PlayRecord
+	for i := range all {
+		r := &all[i]
+		if (to.IsZero() || r.Start.Before(to)) && (from.IsZero() || r.End == nil || r.End.After(from)) {
+			recs = append(recs, *r)
+		}
+	}
+	return recs, nil
+}
+
+// Close the log, ending the plays still going.
+func (pl *PlayLog) Close() error {
+	pl.Lock()
+	defer pl.Unlock()
+	now := time.Now()
+	for _, st := range pl.screens {
+		pl.endLocked(st, now)
+	}
+	return pl.f.Close()
+}
+
+// PlayTotal is how long something was displayed.
+type PlayTotal struct {
+	// Key is the URL or Screen ID the total is for, and Name the Screen's name
+	// when totalling by Screen.
+	Key     string  `json:"key"`
+	Name    string  `json:"name,omitempty"`
+	Plays   int     `json:"plays"`
+	Failed  int     `json:"failed"`
+	Seconds float64 `json:"seconds"`
+}
+
+// PlayReport totals the plays overlapping from and to, by URL if byScreen is
+// false, or else by Screen. Only the time within the range counts.
+func (pl *PlayLog) PlayReport(from, to time.Time, byScreen bool) ([]PlayTotal, error) {
+	recs, err := pl.Records(from, to)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	totals := make(map[string]*PlayTotal)
+	var keys []string
+	for i := range recs {
+		r := &recs[i]
+		key, name := r.URL, ""
+		if byScreen {
+			key, name = r.Screen, r.ScreenName
+		}
+		t := totals[key]
+		if t == nil {
+			t = &PlayTotal{Key: key, Name: name}
+			totals[key] = t
+			keys = append(keys, key)
+		}
+		t.Plays++
+		if !r.Loaded {
+			t.Failed++
+		}
+		t.Seconds += r.seconds(from, to, now)
+	}
+	res := make([]PlayTotal, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, *totals[k])
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Seconds > res[j].Seconds })
+	return res, nil
+}
@@ -0,0 +1,121 @@
+package pijector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// PopupPolicy decides what happens to windows opened by a Screen's page, for
+// example through window.open or a link with target="_blank".
+type PopupPolicy int
+
+const (
+	// ClosePopups closes new windows as soon as they open, leaving the Screen's
+	// page as it was.
+	ClosePopups PopupPolicy = iota
+	// TakeOverPopups makes a new window the Screen's page, and closes the page
+	// which opened it.
+	TakeOverPopups
+)
+
+// ParsePopupPolicy from its name, "close" or "takeover".
+func ParsePopupPolicy(name string) (PopupPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "close":
+		return ClosePopups, nil
+	case "takeover":
+		return TakeOverPopups, nil
+	}
+	return ClosePopups, fmt.Errorf("unknown popup policy %q", name)
+}
+
+func (p PopupPolicy) String() string {
+	if p == TakeOverPopups {
+		return "takeover"
+	}
+	return "close"
+}
+
+// dismissDialogs automatically answers JavaScript dialogs on p, which would
+// otherwise block the page and anything waiting on it. Leaving the page is
+// always allowed; every other dialog is dismissed.
+func (s *localScreen) dismissDialogs(p *rod.Page) {
+	go p.EachEvent(func(e *proto.PageJavascriptDialogOpening) {
+		h := proto.PageHandleJavaScriptDialog{
+			Accept: e.Type == proto.PageDialogTypeBeforeunload,
+		}
+		log := logrus.WithFields(logrus.Fields{
+			"screen":  s.id,
+			"dialog":  e.Type,
+			"message": e.Message,
+			"url":     e.URL,
+		})
+		if err := h.Call(p); err != nil {
+			log.WithError(err).Warn("handling dialog failed")
+			return
+		}
+		log.Info("dismissed dialog")
+	})()
+}
+
+// handlePopups opened by p according to the Screen's popup policy.
+func (s *localScreen) handlePopups(b *rod.Browser, p *rod.Page) {
+	go b.Context(p.GetContext()).EachEvent(func(e *proto.TargetTargetCreated) {
+		info := e.TargetInfo
+		if info.Type != proto.TargetTargetInfoTypePage || info.OpenerID != p.TargetID {
+			return
+		}
+		log := logrus.WithFields(logrus.Fields{
+			"screen": s.id,
+			"url":    info.URL,
+			"policy": s.popups,
+		})
+		if s.popups == TakeOverPopups {
+			// Taking over replaces the watchers of p, including this one, so it
+			// can't wait for the lock here.
+			go s.takeOverPopup(info.TargetID, p.TargetID)
+			log.Info("taking over popup")
+			return
+		}
+		if _, err := (proto.TargetCloseTarget{TargetID: info.TargetID}).Call(b); err != nil {
+			log.WithError(err).Warn("closing popup failed")
+			return
+		}
+		if _, err := p.Activate(); err != nil {
+			log.WithError(err).Debug("reactivating page failed")
+		}
+		log.Info("closed popup")
+	})()
+}
+
+// takeOverPopup makes the popup the current page, if its opener still is.
+func (s *localScreen) takeOverPopup(popup, opener proto.TargetTargetID) {
+	s.Lock()
+	defer s.Unlock()
+	log := logrus.WithField("screen", s.id)
+	if s.current == nil || s.current.TargetID != opener {
+		// The screen moved on since the popup opened.
+		if s.browser != nil {
+			_, _ = (proto.TargetCloseTarget{TargetID: popup}).Call(s.browser)
+		}
+		return
+	}
+	page, err := s.browser.PageFromTarget(popup)
+	if err != nil {
+		log.WithError(err).Warn("taking over popup failed")
+		return
+	}
+	if _, err := page.Activate(); err != nil {
+		log.WithError(err).Warn("taking over popup failed")
+		return
+	}
+	s.current = page
+	s.setupPage(page)
+	if _, err := (proto.TargetCloseTarget{TargetID: opener}).Call(s.browser); err != nil {
+		log.WithError(err).Debug("closing popup opener failed")
+	}
+}
@@ -0,0 +1,250 @@
+package pijector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidSlideAction is returned when asked to take a SlideAction which
+// doesn't exist.
+var ErrInvalidSlideAction = errors.New("invalid slide action")
+
+// MinAutoAdvance is the shortest interval at which slides advance on their own,
+// since each advance holds up the Screen.
+const MinAutoAdvance = time.Second
+
+// SlideAction moves through a deck of slides displayed on a Screen.
+type SlideAction string
+
+const (
+	NextSlide     SlideAction = "next"
+	PreviousSlide SlideAction = "previous"
+	FirstSlide    SlideAction = "first"
+	LastSlide     SlideAction = "last"
+)
+
+// slideKeys are pressed to take each SlideAction. These are understood by
+// Google Slides, reveal.js and PDF viewers alike.
+var slideKeys = map[SlideAction]string{
+	NextSlide:     "ArrowRight",
+	PreviousSlide: "ArrowLeft",
+	FirstSlide:    "Home",
+	LastSlide:     "End",
+}
+
+// ParseSlideAction from its name.
+func ParseSlideAction(name string) (SlideAction, error) {
+	a := SlideAction(strings.ToLower(name))
+	if a == "prev" {
+		a = PreviousSlide
+	}
+	if _, ok := slideKeys[a]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSlideAction, name)
+	}
+	return a, nil
+}
+
+// SlideStatus of a deck displayed on a Screen.
+type SlideStatus struct {
+	// Slide currently displayed, counting from 1, and the Total number of
+	// slides. Both are zero if the page doesn't expose them.
+	Slide int `json:"slide,omitempty"`
+	Total int `json:"total,omitempty"`
+	// AutoAdvance is the number of seconds between automatic advances to the
+	// next slide, or zero if the Screen isn't advancing on its own.
+	AutoAdvance float64 `json:"auto_advance,omitempty"`
+}
+
+// Presenter is implemented by Screens which can be used as a presentation
+// remote.
+type Presenter interface {
+	// Slide reports the SlideStatus of the Screen.
+	Slide() (SlideStatus, error)
+	// Present takes a SlideAction on the deck displayed on the Screen.
+	Present(a SlideAction) (SlideStatus, error)
+	// AutoAdvance to the next slide every interval d, which must be at least
+	// MinAutoAdvance, until the Screen shows something else. Zero stops
+	// advancing.
+	AutoAdvance(d time.Duration) (SlideStatus, error)
+}
+
+// slideScript finds the current slide of a deck, from wherever the page
+// exposes it. Pages can expose their own by setting window.pijectorSlide to
+// {slide, total}, or to a function returning it.
+const slideScript = `() => {
+	const num = (v) => {
+		const n = parseInt(v, 10);
+		return isNaN(n) ? 0 : n;
+	};
+	let exposed = window.pijectorSlide;
+	if (typeof exposed === 'function') {
+		exposed = exposed();
+	}
+	if (exposed && exposed.slide) {
+		return {slide: num(exposed.slide), total: num(exposed.total)};
+	}
+	if (window.Reveal && Reveal.getSlidePastCount) {
+		return {slide: Reveal.getSlidePastCount() + 1, total: Reveal.getTotalSlides()};
+	}
+	if (window.PDFViewerApplication && PDFViewerApplication.page) {
+		return {slide: PDFViewerApplication.page, total: PDFViewerApplication.pagesCount};
+	}
+	const m = location.hash.match(/(?:slide|page)=(\d+)/);
+	return {slide: m ? num(m[1]) : 0, total: 0};
+}`
+
+// autoAdvance to the next slide on an interval.
+type autoAdvance struct {
+	interval time.Duration
+	done     chan struct{}
+}
+
+func newAutoAdvance(d time.Duration, fn func(*autoAdvance)) *autoAdvance {
+	a := &autoAdvance{
+		interval: d,
+		done:     make(chan struct{}),
+	}
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-a.done:
+				return
+			case <-t.C:
+				fn(a)
+			}
+		}
+	}()
+	return a
+}
+
+// stop advancing. Safe to call on a nil autoAdvance.
+func (a *autoAdvance) stop() {
+	if a != nil {
+		close(a.done)
+	}
+}
+
+// seconds between advances. Safe to call on a nil autoAdvance.
+func (a *autoAdvance) seconds() float64 {
+	if a == nil {
+		return 0
+	}
+	return a.interval.Seconds()
+}
+
+// slideLocked reports the SlideStatus of the current page. This function
+// assumes the lock is held before calling.
+func (s *localScreen) slideLocked() SlideStatus {
+	st := SlideStatus{AutoAdvance: s.advance.seconds()}
+	res, err := s.current.Timeout(scriptTimeout).Eval(slideScript)
+	if err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Debug("reading slide number failed")
+		return st
+	}
+	st.Slide = res.Value.Get("slide").Int()
+	st.Total = res.Value.Get("total").Int()
+	return st
+}
+
+// presentLocked takes a on the current page. This function assumes the lock is
+// held before calling.
+func (s *localScreen) presentLocked(a SlideAction) error {
+	kc, err := parseKeyChord(slideKeys[a])
+	if err != nil {
+		return err
+	}
+	return s.checkConn(kc.press(s.current.Timeout(inputTimeout)))
+}
+
+// cancelAdvance stops any automatic advancing. This function assumes the lock
+// is held before calling.
+func (s *localScreen) cancelAdvance() {
+	s.advance.stop()
+	s.advance = nil
+}
+
+// advanceTo the next slide, if a is still the Screen's auto advance.
+func (s *localScreen) advanceTo(a *autoAdvance) {
+	s.Lock()
+	defer s.Unlock()
+	if s.advance != a {
+		return
+	}
+	if err := s.attachIfNecessary(); err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Warn("advancing slide failed")
+		return
+	}
+	if err := s.presentLocked(NextSlide); err != nil {
+		logrus.WithError(err).WithField("screen", s.id).Warn("advancing slide failed")
+	}
+}
+
+func (s *localScreen) Slide() (SlideStatus, error) {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.attachIfNecessary(); err != nil {
+		return SlideStatus{}, err
+	}
+	return s.slideLocked(), nil
+}
+
+func (s *localScreen) Present(a SlideAction) (SlideStatus, error) {
+	if _, ok := slideKeys[a]; !ok {
+		return SlideStatus{}, fmt.Errorf("%w: %q", ErrInvalidSlideAction, a)
+	}
+	s.Lock()
+	defer s.Unlock()
+	if err := s.attachIfNecessary(); err != nil {
+		return SlideStatus{}, err
+	}
+	if err := s.presentLocked(a); err != nil {
+		return SlideStatus{}, err
+	}
+	return s.slideLocked(), nil
+}
+
+func (s *localScreen) AutoAdvance(d time.Duration) (SlideStatus, error) {
+	if d < 0 || (d > 0 && d < MinAutoAdvance) {
+		return SlideStatus{}, fmt.Errorf("%w: %v", errInvalidDuration, d)
+	}
+	s.Lock()
+	defer s.Unlock()
+	if err := s.attachIfNecessary(); err != nil {
+		return SlideStatus{}, err
+	}
+	s.cancelAdvance()
+	if d > 0 {
+		s.advance = newAutoAdvance(d, s.advanceTo)
+		logrus.WithFields(logrus.Fields{
+			"screen":   s.id,
+			"interval": d,
+		}).Info("auto advancing slides")
+	}
+	return s.slideLocked(), nil
+}
+
+func (s *remoteScreen) Slide() (st SlideStatus, err error) {
+	err = s.doJSON(http.MethodGet, "/present", nil, &st)
+	return
+}
+
+func (s *remoteScreen) Present(a SlideAction) (st SlideStatus, err error) {
+	if _, ok := slideKeys[a]; !ok {
+		return st, fmt.Errorf("%w: %q", ErrInvalidSlideAction, a)
+	}
+	err = s.doJSON(http.MethodPost, "/present/"+url.PathEscape(string(a)), nil, &st)
+	return
+}
+
+func (s *remoteScreen) AutoAdvance(d time.Duration) (st SlideStatus, err error) {
+	err = s.doJSON(http.MethodPut, "/present/auto?interval="+url.QueryEscape(d.String()), nil, &st)
+	return
+}
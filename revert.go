@@ -0,0 +1,49 @@
+package pijector
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+var errInvalidDuration = errors.New("invalid duration")
+
+// pendingRevert tracks a time-limited Show which will return a Screen to target
+// when it expires.
+type pendingRevert struct {
+	target  string
+	expires time.Time
+	timer   *time.Timer
+}
+
+func newPendingRevert(target string, d time.Duration, fn func(*pendingRevert)) *pendingRevert {
+	r := &pendingRevert{
+		target:  target,
+		expires: time.Now().Add(d),
+	}
+	r.timer = time.AfterFunc(d, func() { fn(r) })
+	return r
+}
+
+func (r *pendingRevert) stop() {
+	r.timer.Stop()
+}
+
+// remaining whole seconds before the revert, rounded up so that a pending
+// revert never reports zero. Safe to call on a nil pendingRevert.
+func (r *pendingRevert) remaining() int {
+	if r == nil {
+		return 0
+	}
+	left := time.Until(r.expires)
+	if left <= 0 {
+		return 0
+	}
+	return int(math.Ceil(left.Seconds()))
+}
+
+// isRevertible is true for URLs worth returning to after a time-limited Show.
+func isRevertible(u string) bool {
+	return u != "" && !strings.HasPrefix(u, "about:") && !strings.HasPrefix(u, "chrome-error:")
+}